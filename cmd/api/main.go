@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -28,13 +30,19 @@ import (
 // @schemes http
 // @tag.name PDF
 // @tag.description PDF generation endpoints
+// @tag.name Fonts
+// @tag.description Font library endpoints
+// @tag.name Templates
+// @tag.description Template registry endpoints
 // @tag.name Health
 // @tag.description Health check endpoints
 
 const (
-	Version          = "1.0.0"
-	DefaultPort      = "3000"
-	DefaultOutputDir = "./output"
+	Version             = "1.0.0"
+	DefaultPort         = "3000"
+	DefaultOutputDir    = "./output"
+	DefaultFontsDir     = "./fonts"
+	DefaultTemplatesDir = "./templates"
 )
 
 func main() {
@@ -46,7 +54,88 @@ func main() {
 		log.Fatalf("Failed to create job store: %v", err)
 	}
 
-	generator := pdfgen.NewGenerator(60 * time.Second)
+	templates, err := storage.NewTemplateStore(getEnv("TEMPLATES_DIR", DefaultTemplatesDir))
+	if err != nil {
+		log.Fatalf("Failed to create template store: %v", err)
+	}
+
+	if dir := os.Getenv("SNIPPETS_DIR"); dir != "" {
+		n, err := pdfgen.LoadSnippets(dir)
+		if err != nil {
+			log.Fatalf("Failed to load header/footer snippets: %v", err)
+		}
+		log.Printf("Loaded %d header/footer snippets from %s", n, dir)
+	}
+
+	fonts, err := pdfgen.OpenFontLibrary(getEnv("FONTS_DIR", DefaultFontsDir), os.Getenv("GOOGLE_FONTS_OFFLINE") == "true")
+	if err != nil {
+		log.Fatalf("Failed to open font library: %v", err)
+	}
+
+	urlGuard, err := pdfgen.NewURLGuard(getEnvList("URL_GUARD_ALLOW_HOSTS"), getEnvList("URL_GUARD_ALLOW_NETWORKS"))
+	if err != nil {
+		log.Fatalf("Failed to configure URL guard: %v", err)
+	}
+
+	var signer *pdfgen.Signer
+	if certPath := os.Getenv("SIGNING_CERT"); certPath != "" {
+		signer, err = pdfgen.LoadSigner(certPath, os.Getenv("SIGNING_KEY"), os.Getenv("SIGNING_KEY_PASSWORD"))
+		if err != nil {
+			log.Fatalf("Failed to load signing certificate: %v", err)
+		}
+		log.Printf("Signing PDFs as %s", signer.Name())
+	}
+
+	trustedRoots, err := pdfgen.LoadCertPool(getEnvList("SIGNING_TRUSTED_CERTS"))
+	if err != nil {
+		log.Fatalf("Failed to load trusted certificates: %v", err)
+	}
+	if signer != nil {
+		// Our own signatures should verify without extra configuration.
+		trustedRoots.AddCert(signer.Root())
+	}
+	verifyOpts := pdfgen.VerifyOptions{
+		Roots:           trustedRoots,
+		CheckRevocation: os.Getenv("SIGNATURE_CHECK_REVOCATION") == "true",
+	}
+
+	chromeCfg := pdfgen.ChromeConfig{
+		ExecPath:    os.Getenv("CHROME_PATH"),
+		Flags:       getEnvList("CHROME_FLAGS"),
+		UserDataDir: os.Getenv("CHROME_USER_DATA_DIR"),
+		ProxyServer: os.Getenv("CHROME_PROXY_SERVER"),
+		UserAgent:   os.Getenv("CHROME_USER_AGENT"),
+		RemoteURL:   os.Getenv("CHROME_REMOTE_URL"),
+	}
+	if size := os.Getenv("CHROME_WINDOW_SIZE"); size != "" {
+		w, h, _ := strings.Cut(size, "x")
+		width, errW := strconv.Atoi(w)
+		height, errH := strconv.Atoi(h)
+		if errW != nil || errH != nil {
+			log.Fatalf("Invalid CHROME_WINDOW_SIZE=%q, expected WIDTHxHEIGHT", size)
+		}
+		chromeCfg.WindowWidth, chromeCfg.WindowHeight = width, height
+	}
+	if err := chromeCfg.Validate(); err != nil {
+		log.Fatalf("Invalid Chrome configuration: %v", err)
+	}
+	if chromeCfg.RemoteURL != "" {
+		log.Printf("Using remote Chrome at %s", chromeCfg.RemoteURL)
+	}
+
+	poolCfg := pdfgen.DefaultPoolConfig()
+	poolCfg.Browsers = getEnvInt("BROWSER_POOL_SIZE", poolCfg.Browsers)
+	poolCfg.TabsPerBrowser = getEnvInt("BROWSER_TABS_PER_BROWSER", poolCfg.TabsPerBrowser)
+
+	generator := pdfgen.NewGeneratorWithConfig(pdfgen.Config{
+		Timeout:   60 * time.Second,
+		Pool:      poolCfg,
+		Chrome:    chromeCfg,
+		AssetRoot: os.Getenv("ASSETS_DIR"),
+		Fonts:     fonts,
+		URLGuard:  urlGuard,
+		Signer:    signer,
+	})
 
 	app := fiber.New(fiber.Config{
 		AppName:               "PDF Generation API",
@@ -63,8 +152,10 @@ func main() {
 	app.Use(middleware.RequestLogger())
 	app.Use(compress.New())
 
-	pdfHandler := handlers.NewPDFHandler(generator, store)
+	pdfHandler := handlers.NewPDFHandler(generator, store, templates, verifyOpts)
 	healthHandler := handlers.NewHealthHandler(store, Version)
+	fontHandler := handlers.NewFontHandler(fonts)
+	templateHandler := handlers.NewTemplateHandler(templates, store)
 
 	app.Get("/health", healthHandler.HealthCheck)
 	app.Get("/swagger/*", swagger.HandlerDefault)
@@ -73,9 +164,37 @@ func main() {
 	pdf := v1.Group("/pdf")
 	pdf.Post("/generate", pdfHandler.GeneratePDF)
 	pdf.Post("/generate/url", pdfHandler.GenerateFromURL)
+	pdf.Post("/generate/template", pdfHandler.GenerateFromTemplate)
+	pdf.Post("/merge", pdfHandler.MergePDFs)
+	pdf.Post("/verify", pdfHandler.VerifyPDF)
 	pdf.Get("/status/:id", pdfHandler.GetJobStatus)
 	pdf.Get("/download/:id", pdfHandler.DownloadPDF)
+	pdf.Post("/cancel/:id", pdfHandler.CancelJob)
 	pdf.Get("/jobs", pdfHandler.ListJobs)
+	pdf.Get("/jobs/:id/diagnostics", pdfHandler.GetJobDiagnostics)
+	pdf.Post("/jobs/:id/split", pdfHandler.SplitPDF)
+	pdf.Post("/jobs/:id/extract", pdfHandler.ExtractPages)
+	pdf.Post("/jobs/:id/reorder", pdfHandler.ReorderPages)
+	pdf.Post("/jobs/:id/rotate", pdfHandler.RotatePages)
+	pdf.Post("/jobs/:id/watermark", pdfHandler.WatermarkPDF)
+	pdf.Get("/snippets", pdfHandler.ListSnippets)
+
+	fontRoutes := v1.Group("/fonts")
+	fontRoutes.Post("/", fontHandler.UploadFont)
+	fontRoutes.Get("/", fontHandler.ListFonts)
+	fontRoutes.Post("/google", fontHandler.MirrorGoogleFonts)
+
+	templateRoutes := v1.Group("/templates")
+	templateRoutes.Get("/", templateHandler.ListTemplates)
+	templateRoutes.Post("/:name/versions", templateHandler.CreateVersion)
+	templateRoutes.Get("/:name/versions", templateHandler.ListVersions)
+	templateRoutes.Put("/:name/aliases/:alias", templateHandler.SetAlias)
+	templateRoutes.Delete("/:name/aliases/:alias", templateHandler.DeleteAlias)
+	templateRoutes.Get("/:name/assets", templateHandler.ListAssets)
+	templateRoutes.Post("/:name/assets", templateHandler.UploadAsset)
+	templateRoutes.Delete("/:name/assets/*", templateHandler.DeleteAsset)
+	templateRoutes.Get("/:ref", templateHandler.GetTemplate)
+	templateRoutes.Delete("/:name", templateHandler.DeleteTemplate)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -105,7 +224,8 @@ func main() {
 
 		log.Println("Shutting down server...")
 
-		// Close browser instance
+		// Abort running renders, then close browser pool
+		pdfHandler.Shutdown()
 		generator.Close()
 
 		if err := app.Shutdown(); err != nil {
@@ -130,3 +250,23 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("Ignoring invalid %s=%q", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
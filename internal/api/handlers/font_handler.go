@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/api/models"
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/pkg/pdfgen"
+	"github.com/gofiber/fiber/v2"
+)
+
+type FontHandler struct {
+	library *pdfgen.FontLibrary
+}
+
+func NewFontHandler(library *pdfgen.FontLibrary) *FontHandler {
+	return &FontHandler{
+		library: library,
+	}
+}
+
+// @Summary Upload a font
+// @Description Register a ttf, otf, woff or woff2 file so renders can use its family without network access
+// @Tags Fonts
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Font file"
+// @Param family formData string true "Font family name"
+// @Param weight formData int false "Font weight (default 400)"
+// @Param style formData string false "normal or italic (default normal)"
+// @Success 201 {object} models.FontResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/fonts [post]
+func (h *FontHandler) UploadFont(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "file is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	weight := 0
+	if value := c.FormValue("weight"); value != "" {
+		weight, err = strconv.Atoi(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: "weight must be a number",
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid upload",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid upload",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	font, err := h.library.Add(c.FormValue("family"), weight, pdfgen.FontStyle(c.FormValue("style")), data)
+	if err != nil {
+		if errors.Is(err, pdfgen.ErrInvalidFont) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fontResponse(font))
+}
+
+// @Summary List fonts
+// @Description Get the fonts registered in the font library
+// @Tags Fonts
+// @Produce json
+// @Success 200 {object} models.ListFontsResponse
+// @Router /api/fonts [get]
+func (h *FontHandler) ListFonts(c *fiber.Ctx) error {
+	fonts := h.library.Fonts()
+	response := models.ListFontsResponse{
+		Fonts: make([]models.FontResponse, 0, len(fonts)),
+	}
+	for _, font := range fonts {
+		response.Fonts = append(response.Fonts, fontResponse(font))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Mirror Google Fonts
+// @Description Download a Google Fonts stylesheet and its font files so renders using it work offline
+// @Tags Fonts
+// @Accept json
+// @Produce json
+// @Param request body models.MirrorGoogleFontsRequest true "Stylesheet URL"
+// @Success 200 {object} models.MirrorGoogleFontsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/fonts/google [post]
+func (h *FontHandler) MirrorGoogleFonts(c *fiber.Ctx) error {
+	var req models.MirrorGoogleFontsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	files, err := h.library.MirrorGoogleFonts(c.UserContext(), req.URL)
+	if err != nil {
+		if errors.Is(err, pdfgen.ErrInvalidGoogleFontURL) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "Mirroring failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadGateway,
+		})
+	}
+
+	return c.JSON(models.MirrorGoogleFontsResponse{
+		URL:   req.URL,
+		Files: files,
+	})
+}
+
+func fontResponse(font pdfgen.Font) models.FontResponse {
+	return models.FontResponse{
+		Family: font.Family,
+		Weight: font.Weight,
+		Style:  string(font.Style),
+		Format: font.Format,
+		Size:   font.Size,
+	}
+}
@@ -37,6 +37,7 @@ func (h *HealthHandler) HealthCheck(c *fiber.Ctx) error {
 		"processing_jobs": fmt.Sprintf("%d", stats["processing"]),
 		"completed_jobs":  fmt.Sprintf("%d", stats["completed"]),
 		"failed_jobs":     fmt.Sprintf("%d", stats["failed"]),
+		"cancelled_jobs":  fmt.Sprintf("%d", stats["cancelled"]),
 	}
 
 	return c.JSON(models.HealthResponse{
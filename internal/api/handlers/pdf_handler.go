@@ -1,8 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/api/models"
 	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/storage"
@@ -14,15 +27,35 @@ import (
 type PDFHandler struct {
 	generator *pdfgen.Generator
 	store     *storage.JobStore
+	templates *storage.TemplateStore
+	verify    pdfgen.VerifyOptions
+
+	// Jobs outlive the request that created them, so each one runs under
+	// its own context derived from baseCtx rather than the fiber context.
+	baseCtx    context.Context
+	cancelAll  context.CancelFunc
+	running    map[string]context.CancelFunc
+	runningMux sync.Mutex
 }
 
-func NewPDFHandler(generator *pdfgen.Generator, store *storage.JobStore) *PDFHandler {
+func NewPDFHandler(generator *pdfgen.Generator, store *storage.JobStore, templates *storage.TemplateStore, verify pdfgen.VerifyOptions) *PDFHandler {
+	baseCtx, cancelAll := context.WithCancel(context.Background())
 	return &PDFHandler{
 		generator: generator,
 		store:     store,
+		templates: templates,
+		verify:    verify,
+		baseCtx:   baseCtx,
+		cancelAll: cancelAll,
+		running:   make(map[string]context.CancelFunc),
 	}
 }
 
+// Shutdown cancels every job that is still rendering.
+func (h *PDFHandler) Shutdown() {
+	h.cancelAll()
+}
+
 // @Summary Generate PDF from HTML
 // @Description Generate a PDF document from HTML content
 // @Tags PDF
@@ -51,10 +84,19 @@ func (h *PDFHandler) GeneratePDF(c *fiber.Ctx) error {
 		})
 	}
 
+	opts, err := h.convertPrintOptions(req.Options)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
 	jobID := uuid.New().String()
 	job := h.store.CreateJob(jobID, req.HTML, req.Filename, req.Options)
 
-	go h.processJob(job)
+	go h.processJob(h.startJob(job.ID), job, opts)
 
 	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
 		JobID:     jobID,
@@ -64,6 +106,118 @@ func (h *PDFHandler) GeneratePDF(c *fiber.Ctx) error {
 	})
 }
 
+// @Summary Generate PDF from template
+// @Description Render a Go html/template with JSON data and generate a PDF from the result
+// @Tags PDF
+// @Accept json
+// @Produce json
+// @Param request body models.GenerateFromTemplateRequest true "Template generation request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.TemplateErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/pdf/generate/template [post]
+func (h *PDFHandler) GenerateFromTemplate(c *fiber.Ctx) error {
+	var req models.GenerateFromTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if (req.Template == "") == (req.TemplateRef == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "exactly one of template or template_ref is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	opts, err := h.convertPrintOptions(req.Options)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	tmpl := &pdfgen.Template{Text: req.Template, Partials: req.Partials}
+	var ref *models.TemplateRef
+	if req.TemplateRef != "" {
+		version, err := h.templates.Resolve(req.TemplateRef)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Not found",
+				Message: err.Error(),
+				Code:    fiber.StatusNotFound,
+			})
+		}
+		tmpl = &pdfgen.Template{Text: version.Template, Partials: version.Partials}
+		ref = &models.TemplateRef{Name: version.Name, Version: version.Version}
+		if len(version.Assets) > 0 {
+			opts.AssetDir = version.AssetsDir
+		}
+	}
+
+	// Decoded with UseNumber so large IDs and exact amounts survive.
+	var data any
+	if len(req.Data) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(req.Data))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: "data: " + err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
+
+	html, err := pdfgen.ExecuteTemplate(c.UserContext(), tmpl, data)
+	if err != nil {
+		return templateErrorResponse(c, err)
+	}
+
+	jobID := uuid.New().String()
+	job := h.store.CreateJob(jobID, html, req.Filename, req.Options)
+	if ref != nil {
+		h.store.SetTemplate(job.ID, ref)
+	}
+
+	go h.processJob(h.startJob(job.ID), job, opts)
+
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     jobID,
+		Status:    models.JobStatusPending,
+		Message:   "PDF generation from template queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+func templateErrorResponse(c *fiber.Ctx, err error) error {
+	var tmplErr *pdfgen.TemplateError
+	if !errors.As(err, &tmplErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Template error",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(models.TemplateErrorResponse{
+		ErrorResponse: models.ErrorResponse{
+			Error:   "Template error",
+			Message: tmplErr.Message,
+			Code:    fiber.StatusBadRequest,
+		},
+		Template: tmplErr.Template,
+		Line:     tmplErr.Line,
+		Column:   tmplErr.Column,
+	})
+}
+
 // @Summary Generate PDF from URL
 // @Description Generate a PDF document from a web URL
 // @Tags PDF
@@ -92,11 +246,38 @@ func (h *PDFHandler) GenerateFromURL(c *fiber.Ctx) error {
 		})
 	}
 
+	opts, err := h.convertPrintOptions(req.Options)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	targetURL, creds, err := urlCredentials(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	opts.Credentials = creds
+
+	if err := h.generator.CheckURL(c.UserContext(), targetURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "URL not allowed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
 	jobID := uuid.New().String()
-	html := fmt.Sprintf("URL:%s", req.URL)
+	html := fmt.Sprintf("URL:%s", targetURL)
 	job := h.store.CreateJob(jobID, html, req.Filename, req.Options)
 
-	go h.processURLJob(job, req.URL)
+	go h.processURLJob(h.startJob(job.ID), job, targetURL, opts)
 
 	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
 		JobID:     jobID,
@@ -106,178 +287,1292 @@ func (h *PDFHandler) GenerateFromURL(c *fiber.Ctx) error {
 	})
 }
 
-// @Summary Get job status
-// @Description Get the status of a PDF generation job
+// @Summary Merge PDFs
+// @Description Concatenate the output of completed PDF jobs and uploaded PDFs, in order, into a new job. Parts with a bookmark get a top-level bookmark at their first page.
 // @Tags PDF
+// @Accept json
 // @Produce json
-// @Param id path string true "Job ID"
-// @Success 200 {object} models.JobStatusResponse
+// @Param request body models.MergePDFsRequest true "Merge request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
-// @Router /api/pdf/status/{id} [get]
-func (h *PDFHandler) GetJobStatus(c *fiber.Ctx) error {
-	jobID := c.Params("id")
-
-	job, err := h.store.GetJob(jobID)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error:   "Not found",
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/merge [post]
+func (h *PDFHandler) MergePDFs(c *fiber.Ctx) error {
+	var req models.MergePDFsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
 			Message: err.Error(),
-			Code:    fiber.StatusNotFound,
+			Code:    fiber.StatusBadRequest,
 		})
 	}
 
-	response := models.JobStatusResponse{
-		JobID:        job.ID,
-		Status:       job.Status,
-		Filename:     job.Filename,
-		FileSize:     job.FileSize,
-		ErrorMessage: job.ErrorMessage,
-		Progress:     job.Progress,
-		CreatedAt:    job.CreatedAt,
-		CompletedAt:  job.CompletedAt,
+	if len(req.Parts) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "at least two parts are required",
+			Code:    fiber.StatusBadRequest,
+		})
 	}
 
-	if job.Status == models.JobStatusCompleted {
-		response.DownloadURL = fmt.Sprintf("/api/pdf/download/%s", job.ID)
+	parts := make([]pdfgen.MergePart, 0, len(req.Parts))
+	var sourceJobs []string
+	var files []*os.File
+	release := func() {
+		for _, f := range files {
+			f.Close()
+		}
+		for _, id := range sourceJobs {
+			h.store.ReleaseFile(id)
+		}
 	}
 
-	return c.JSON(response)
-}
+	for i, part := range req.Parts {
+		name := fmt.Sprintf("part %d", i+1)
+		if (part.JobID == "") == (len(part.PDF) == 0) {
+			release()
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: name + " needs exactly one of job_id or pdf",
+				Code:    fiber.StatusBadRequest,
+			})
+		}
 
-// @Summary Download generated PDF
-// @Description Download a completed PDF file
-// @Tags PDF
-// @Produce application/pdf
-// @Param id path string true "Job ID"
-// @Success 200 {file} binary
-// @Failure 404 {object} models.ErrorResponse
-// @Router /api/pdf/download/{id} [get]
-func (h *PDFHandler) DownloadPDF(c *fiber.Ctx) error {
-	jobID := c.Params("id")
+		if len(part.PDF) > 0 {
+			if !isPDF(part.PDF) {
+				release()
+				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+					Error:   "Validation failed",
+					Message: name + " is not a PDF",
+					Code:    fiber.StatusBadRequest,
+				})
+			}
+			parts = append(parts, pdfgen.MergePart{Name: name, PDF: bytes.NewReader(part.PDF), Bookmark: part.Bookmark})
+			continue
+		}
 
-	filePath, err := h.store.GetFilePath(jobID)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error:   "Not found",
-			Message: err.Error(),
-			Code:    fiber.StatusNotFound,
-		})
+		path, errResp := h.sourceJobFile(part.JobID)
+		if errResp != nil {
+			release()
+			return c.Status(errResp.Code).JSON(errResp)
+		}
+		sourceJobs = append(sourceJobs, part.JobID)
+
+		f, err := os.Open(path)
+		if err != nil {
+			release()
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: err.Error(),
+				Code:    fiber.StatusInternalServerError,
+			})
+		}
+		files = append(files, f)
+		parts = append(parts, pdfgen.MergePart{Name: name + " (job " + part.JobID + ")", PDF: f, Bookmark: part.Bookmark})
 	}
-	defer h.store.ReleaseFile(jobID)
 
-	job, _ := h.store.GetJob(jobID)
-	c.Set("Content-Type", "application/pdf")
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", job.Filename))
+	jobID := uuid.New().String()
+	job := h.store.CreateJob(jobID, "", req.Filename, nil)
+	if len(sourceJobs) > 0 {
+		h.store.SetSourceJobs(job.ID, sourceJobs)
+	}
 
-	return c.SendFile(filePath)
+	go h.processMergeJob(h.startJob(job.ID), job, parts, release)
+
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     jobID,
+		Status:    models.JobStatusPending,
+		Message:   "PDF merge queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
 }
 
-// @Summary List all jobs
-// @Description Get a paginated list of all PDF generation jobs
+// @Summary Split a PDF
+// @Description Split a completed job's PDF into one new job per page range, or per page when no ranges are given
 // @Tags PDF
+// @Accept json
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param page_size query int false "Page size" default(20)
-// @Success 200 {object} models.ListJobsResponse
-// @Router /api/pdf/jobs [get]
-func (h *PDFHandler) ListJobs(c *fiber.Ctx) error {
-	page := c.QueryInt("page", 1)
-	pageSize := c.QueryInt("page_size", 20)
+// @Param id path string true "Job ID"
+// @Param request body models.SplitPDFRequest false "Split request"
+// @Success 202 {object} models.SplitPDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/split [post]
+func (h *PDFHandler) SplitPDF(c *fiber.Ctx) error {
+	var req models.SplitPDFRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
 
-	if page < 1 {
-		page = 1
+	sourceID := c.Params("id")
+	path, pageCount, errResp := h.sourcePDF(sourceID)
+	if errResp != nil {
+		return c.Status(errResp.Code).JSON(errResp)
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+
+	var ranges []pdfgen.PageRange
+	if req.Ranges == "" {
+		for p := 1; p <= pageCount; p++ {
+			ranges = append(ranges, pdfgen.PageRange{From: p, To: p})
+		}
+	} else {
+		var err error
+		if ranges, err = pdfgen.ParsePageRanges(req.Ranges); err != nil {
+			h.store.ReleaseFile(sourceID)
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
 	}
 
-	jobs, total := h.store.ListJobs(page, pageSize)
+	if len(ranges) > maxSplitParts {
+		h.store.ReleaseFile(sourceID)
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: fmt.Sprintf("a split can produce at most %d files", maxSplitParts),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
 
-	jobStatuses := make([]models.JobStatusResponse, 0, len(jobs))
-	for _, job := range jobs {
-		status := models.JobStatusResponse{
-			JobID:        job.ID,
-			Status:       job.Status,
-			Filename:     job.Filename,
-			FileSize:     job.FileSize,
-			ErrorMessage: job.ErrorMessage,
-			Progress:     job.Progress,
-			CreatedAt:    job.CreatedAt,
-			CompletedAt:  job.CompletedAt,
+	// The source is read once, by the first part to run, and shared by the
+	// rest; queuePageJobs runs them one after another.
+	var doc *pdfgen.Document
+	readSource := func(ctx context.Context, src string) (*pdfgen.Document, error) {
+		if doc == nil {
+			d, err := pdfgen.ReadDocument(ctx, src)
+			if err != nil {
+				return nil, err
+			}
+			doc = d
 		}
+		return doc, nil
+	}
 
-		if job.Status == models.JobStatusCompleted {
-			status.DownloadURL = fmt.Sprintf("/api/pdf/download/%s", job.ID)
+	outputs := make([]pageOutput, 0, len(ranges))
+	for _, r := range ranges {
+		pages, err := pdfgen.ExpandPageRanges([]pdfgen.PageRange{r}, pageCount)
+		if err != nil {
+			h.store.ReleaseFile(sourceID)
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
 		}
-
-		jobStatuses = append(jobStatuses, status)
+		label := fmt.Sprintf("%d-%d", pages[0], pages[len(pages)-1])
+		if len(pages) == 1 {
+			label = strconv.Itoa(pages[0])
+		}
+		outputs = append(outputs, pageOutput{
+			pages:    label,
+			filename: suffixFilename(req.Filename, label),
+			run: func(ctx context.Context, src, dst string) error {
+				doc, err := readSource(ctx, src)
+				if err != nil {
+					return err
+				}
+				return doc.SelectPages(ctx, pages, dst)
+			},
+		})
 	}
 
-	totalPages := (total + pageSize - 1) / pageSize
+	jobs := h.queuePageJobs(sourceID, path, outputs)
 
-	return c.JSON(models.ListJobsResponse{
-		Jobs:       jobStatuses,
-		Total:      total,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: totalPages,
-	})
+	response := models.SplitPDFResponse{
+		Jobs:    make([]models.SplitJob, 0, len(jobs)),
+		Message: fmt.Sprintf("PDF split into %d files queued successfully", len(jobs)),
+	}
+	for i, job := range jobs {
+		response.Jobs = append(response.Jobs, models.SplitJob{
+			JobID:     job.ID,
+			Pages:     outputs[i].pages,
+			Status:    models.JobStatusPending,
+			CreatedAt: job.CreatedAt,
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(response)
 }
 
-func (h *PDFHandler) processJob(job *storage.Job) {
-	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+// @Summary Extract pages
+// @Description Create a new job with the selected pages of a completed job's PDF, in document order
+// @Tags PDF
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body models.ExtractPagesRequest true "Extract request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/extract [post]
+func (h *PDFHandler) ExtractPages(c *fiber.Ctx) error {
+	var req models.ExtractPagesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
 
-	opts := convertPrintOptions(job.Options)
-	err := h.generator.FromHTMLWithCustomOptions(job.HTML, job.FilePath, opts)
+	sourceID := c.Params("id")
+	path, pageCount, errResp := h.sourcePDF(sourceID)
+	if errResp != nil {
+		return c.Status(errResp.Code).JSON(errResp)
+	}
 
+	pages, err := selectedPages(req.Pages, pageCount)
 	if err != nil {
-		log.Printf("Job %s failed: %v", job.ID, err)
-		h.store.UpdateJobStatus(job.ID, models.JobStatusFailed, err.Error())
-	} else {
-		log.Printf("Job %s completed successfully", job.ID)
-		h.store.UpdateJobStatus(job.ID, models.JobStatusCompleted, "")
+		h.store.ReleaseFile(sourceID)
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
 	}
-}
-
-func (h *PDFHandler) processURLJob(job *storage.Job, url string) {
-	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+	slices.Sort(pages)
 
-	opts := convertPrintOptions(job.Options)
-	err := h.generator.FromURLWithCustomOptions(url, job.FilePath, opts)
+	job := h.queuePageJobs(sourceID, path, []pageOutput{{
+		filename: req.Filename,
+		run: func(ctx context.Context, src, dst string) error {
+			return pdfgen.SelectPages(ctx, src, pages, dst)
+		},
+	}})[0]
 
-	if err != nil {
-		log.Printf("URL Job %s failed: %v", job.ID, err)
-		h.store.UpdateJobStatus(job.ID, models.JobStatusFailed, err.Error())
-	} else {
-		log.Printf("URL Job %s completed successfully", job.ID)
-		h.store.UpdateJobStatus(job.ID, models.JobStatusCompleted, "")
-	}
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusPending,
+		Message:   "Page extraction queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
 }
 
-func convertPrintOptions(opts *models.PrintOptions) *pdfgen.PrintOptions {
-	if opts == nil {
-		return pdfgen.DefaultPrintOptions()
+// @Summary Reorder pages
+// @Description Create a new job with a completed job's pages in a new order. The order must list every page exactly once.
+// @Tags PDF
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body models.ReorderPagesRequest true "Reorder request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/reorder [post]
+func (h *PDFHandler) ReorderPages(c *fiber.Ctx) error {
+	var req models.ReorderPagesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
 	}
 
-	pdfOpts := pdfgen.DefaultPrintOptions()
-	pdfOpts.Landscape = opts.Landscape
-	pdfOpts.PrintBackground = opts.PrintBackground
-	pdfOpts.MarginTop = opts.MarginTop
-	pdfOpts.MarginBottom = opts.MarginBottom
-	pdfOpts.MarginLeft = opts.MarginLeft
-	pdfOpts.MarginRight = opts.MarginRight
-	pdfOpts.Scale = opts.Scale
+	sourceID := c.Params("id")
+	path, pageCount, errResp := h.sourcePDF(sourceID)
+	if errResp != nil {
+		return c.Status(errResp.Code).JSON(errResp)
+	}
 
-	switch opts.PageSize {
-	case "A4":
-		pdfOpts.PageSize = pdfgen.PageSizeA4
-	case "Letter":
-		pdfOpts.PageSize = pdfgen.PageSizeLetter
-	case "Legal":
-		pdfOpts.PageSize = pdfgen.PageSizeLegal
-	default:
-		pdfOpts.PageSize = pdfgen.PageSizeA4
+	pages, err := pageOrder(req.Order, pageCount)
+	if err != nil {
+		h.store.ReleaseFile(sourceID)
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	job := h.queuePageJobs(sourceID, path, []pageOutput{{
+		filename: req.Filename,
+		run: func(ctx context.Context, src, dst string) error {
+			return pdfgen.SelectPages(ctx, src, pages, dst)
+		},
+	}})[0]
+
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusPending,
+		Message:   "Page reordering queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// @Summary Rotate pages
+// @Description Create a new job with pages of a completed job's PDF rotated clockwise by a multiple of 90 degrees
+// @Tags PDF
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body models.RotatePagesRequest true "Rotate request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/rotate [post]
+func (h *PDFHandler) RotatePages(c *fiber.Ctx) error {
+	var req models.RotatePagesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if req.Degrees == 0 || req.Degrees%90 != 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "degrees must be a non-zero multiple of 90",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	sourceID := c.Params("id")
+	path, pageCount, errResp := h.sourcePDF(sourceID)
+	if errResp != nil {
+		return c.Status(errResp.Code).JSON(errResp)
+	}
+
+	var pages []int
+	if req.Pages != "" {
+		var err error
+		if pages, err = selectedPages(req.Pages, pageCount); err != nil {
+			h.store.ReleaseFile(sourceID)
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
+
+	job := h.queuePageJobs(sourceID, path, []pageOutput{{
+		filename: req.Filename,
+		run: func(ctx context.Context, src, dst string) error {
+			return pdfgen.RotatePages(ctx, src, req.Degrees, pages, dst)
+		},
+	}})[0]
+
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusPending,
+		Message:   "Page rotation queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// @Summary Watermark a PDF
+// @Description Create a new job with text or image watermarks stamped over, or under, the pages of a completed job's PDF
+// @Tags PDF
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body models.WatermarkPDFRequest true "Watermark request"
+// @Success 202 {object} models.GeneratePDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/watermark [post]
+func (h *PDFHandler) WatermarkPDF(c *fiber.Ctx) error {
+	var req models.WatermarkPDFRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if len(req.Watermarks) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "at least one watermark is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	watermarks := convertWatermarks(req.Watermarks)
+	for i := range watermarks {
+		if err := watermarks[i].Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: fmt.Sprintf("watermarks[%d]: %v", i, err),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
+
+	sourceID := c.Params("id")
+	path, pageCount, errResp := h.sourcePDF(sourceID)
+	if errResp != nil {
+		return c.Status(errResp.Code).JSON(errResp)
+	}
+
+	for i, w := range watermarks {
+		if w.Pages == "" {
+			continue
+		}
+		if _, err := selectedPages(w.Pages, pageCount); err != nil {
+			h.store.ReleaseFile(sourceID)
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: fmt.Sprintf("watermarks[%d]: %v", i, err),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+	}
+
+	job := h.queuePageJobs(sourceID, path, []pageOutput{{
+		filename: req.Filename,
+		run: func(ctx context.Context, src, dst string) error {
+			return pdfgen.ApplyWatermarks(ctx, src, watermarks, dst)
+		},
+	}})[0]
+
+	return c.Status(fiber.StatusAccepted).JSON(models.GeneratePDFResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusPending,
+		Message:   "Watermarking queued successfully",
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// @Summary Verify PDF signatures
+// @Description Check every digital signature in an uploaded PDF: whether the document changed after signing and whether the signer's certificate is trusted
+// @Tags PDF
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "PDF file"
+// @Success 200 {object} models.VerifyPDFResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/pdf/verify [post]
+func (h *PDFHandler) VerifyPDF(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "file is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid upload",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid upload",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	if !isPDF(data) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "file is not a PDF",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	infos, err := pdfgen.VerifySignatures(bytes.NewReader(data), h.verify)
+	if err != nil {
+		if errors.Is(err, pdfgen.ErrInvalidPDF) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    fiber.StatusBadRequest,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		})
+	}
+
+	response := models.VerifyPDFResponse{
+		Signed:     len(infos) > 0,
+		Valid:      len(infos) > 0,
+		Signatures: make([]models.SignatureResult, 0, len(infos)),
+	}
+	for _, info := range infos {
+		if info.Status != pdfgen.SignatureValid {
+			response.Valid = false
+		}
+		response.Signatures = append(response.Signatures, signatureResult(info))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Get job status
+// @Description Get the status of a PDF generation job
+// @Tags PDF
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobStatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pdf/status/{id} [get]
+func (h *PDFHandler) GetJobStatus(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, err := h.store.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	return c.JSON(jobStatusResponse(job))
+}
+
+// @Summary Download generated PDF
+// @Description Download a completed PDF or image file
+// @Tags PDF
+// @Produce application/pdf,image/png,image/jpeg,image/webp
+// @Param id path string true "Job ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pdf/download/{id} [get]
+func (h *PDFHandler) DownloadPDF(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	filePath, err := h.store.GetFilePath(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	}
+	defer h.store.ReleaseFile(jobID)
+
+	job, err := h.store.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	}
+	c.Set("Content-Type", pdfgen.OutputFormat(job.OutputType).ContentType())
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", job.Filename))
+
+	return c.SendFile(filePath)
+}
+
+// @Summary Cancel a job
+// @Description Cancel a pending or processing PDF generation job
+// @Tags PDF
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobStatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/pdf/cancel/{id} [post]
+func (h *PDFHandler) CancelJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, err := h.store.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	h.runningMux.Lock()
+	cancel, running := h.running[jobID]
+	h.runningMux.Unlock()
+
+	if !running {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Conflict",
+			Message: fmt.Sprintf("job is already %s", job.Status),
+			Code:    fiber.StatusConflict,
+		})
+	}
+
+	cancel()
+
+	return c.JSON(models.JobStatusResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusCancelled,
+		Filename:  job.Filename,
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// @Summary List all jobs
+// @Description Get a paginated list of all PDF generation jobs
+// @Tags PDF
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} models.ListJobsResponse
+// @Router /api/pdf/jobs [get]
+func (h *PDFHandler) ListJobs(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("page_size", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	jobs, total := h.store.ListJobs(page, pageSize)
+
+	jobStatuses := make([]models.JobStatusResponse, 0, len(jobs))
+	for _, job := range jobs {
+		jobStatuses = append(jobStatuses, jobStatusResponse(job))
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+
+	return c.JSON(models.ListJobsResponse{
+		Jobs:       jobStatuses,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+}
+
+// @Summary List header/footer snippets
+// @Description Get the names of reusable header and footer templates
+// @Tags PDF
+// @Produce json
+// @Success 200 {object} models.ListSnippetsResponse
+// @Router /api/pdf/snippets [get]
+func (h *PDFHandler) ListSnippets(c *fiber.Ctx) error {
+	return c.JSON(models.ListSnippetsResponse{
+		Snippets: pdfgen.SnippetNames(),
+	})
+}
+
+// @Summary Get job diagnostics
+// @Description Get console output, uncaught exceptions, failed and blocked requests recorded while rendering a job
+// @Tags PDF
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.DiagnosticsResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pdf/jobs/{id}/diagnostics [get]
+func (h *PDFHandler) GetJobDiagnostics(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, err := h.store.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	response := models.DiagnosticsResponse{
+		JobID:       job.ID,
+		Status:      job.Status,
+		Diagnostics: *diagnostics(&pdfgen.Diagnostics{}),
+	}
+	if job.Diagnostics != nil {
+		response.Diagnostics = *job.Diagnostics
+	}
+	return c.JSON(response)
+}
+
+func jobStatusResponse(job *storage.Job) models.JobStatusResponse {
+	response := models.JobStatusResponse{
+		JobID:           job.ID,
+		Status:          job.Status,
+		Filename:        job.Filename,
+		FileSize:        job.FileSize,
+		ErrorMessage:    job.ErrorMessage,
+		Progress:        job.Progress,
+		CreatedAt:       job.CreatedAt,
+		CompletedAt:     job.CompletedAt,
+		OutputType:      job.OutputType,
+		AppliedPageSize: job.PageSize,
+		SourceStatus:    job.SourceStatus,
+		Template:        job.Template,
+		SourceJobs:      job.SourceJobs,
+		Encrypted:       job.Options != nil && job.Options.Encryption != nil,
+		Signed:          job.Options != nil && job.Options.Signature != nil,
+	}
+
+	if job.Status == models.JobStatusCompleted {
+		response.DownloadURL = fmt.Sprintf("/api/pdf/download/%s", job.ID)
+	}
+
+	return response
+}
+
+func signatureResult(info pdfgen.SignatureInfo) models.SignatureResult {
+	result := models.SignatureResult{
+		Field:             info.Field,
+		Status:            string(info.Status),
+		Detail:            info.Detail,
+		Modified:          info.Modified,
+		Trusted:           info.Trusted,
+		RevocationChecked: info.RevocationChecked,
+		SignerName:        info.SignerName,
+		Reason:            info.Reason,
+		Location:          info.Location,
+		ContactInfo:       info.ContactInfo,
+		SubFilter:         info.SubFilter,
+		PAdES:             info.PAdES,
+		Certified:         info.Certified,
+		Visible:           info.Visible,
+		Page:              info.Page,
+		Problems:          info.Problems,
+	}
+	if !info.SigningTime.IsZero() {
+		result.SigningTime = &info.SigningTime
+	}
+	if cert := info.Signer; cert != nil {
+		result.Certificate = &models.SignerCertificate{
+			Subject:      cert.Subject,
+			Issuer:       cert.Issuer,
+			SerialNumber: cert.SerialNumber,
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			SelfSigned:   cert.SelfSigned,
+			Expired:      cert.Expired,
+		}
+	}
+	return result
+}
+
+func (h *PDFHandler) startJob(jobID string) context.Context {
+	ctx, cancel := context.WithCancel(h.baseCtx)
+
+	h.runningMux.Lock()
+	h.running[jobID] = cancel
+	h.runningMux.Unlock()
+
+	return ctx
+}
+
+func (h *PDFHandler) finishJob(jobID string) {
+	h.runningMux.Lock()
+	cancel, ok := h.running[jobID]
+	delete(h.running, jobID)
+	h.runningMux.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+func (h *PDFHandler) processJob(ctx context.Context, job *storage.Job, opts *pdfgen.PrintOptions) {
+	defer h.finishJob(job.ID)
+	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+
+	res, err := h.generator.RenderFile(ctx, pdfgen.HTMLSource(job.HTML), job.FilePath, opts)
+
+	h.completeJob(job, "Job", res, err)
+}
+
+func (h *PDFHandler) processURLJob(ctx context.Context, job *storage.Job, targetURL string, opts *pdfgen.PrintOptions) {
+	defer h.finishJob(job.ID)
+	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+
+	res, err := h.generator.RenderFile(ctx, pdfgen.URLSource(targetURL), job.FilePath, opts)
+
+	h.completeJob(job, "URL Job", res, err)
+}
+
+func (h *PDFHandler) processMergeJob(ctx context.Context, job *storage.Job, parts []pdfgen.MergePart, release func()) {
+	defer h.finishJob(job.ID)
+	defer release()
+	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+
+	err := pdfgen.Merge(ctx, parts, job.FilePath)
+
+	h.completeJob(job, "Merge Job", nil, err)
+}
+
+const maxSplitParts = 200
+
+// pageOutput is one new job produced from a completed job's PDF.
+type pageOutput struct {
+	pages    string
+	filename string
+	run      func(ctx context.Context, src, dst string) error
+}
+
+// sourcePDF is sourceJobFile plus the page count that page operations
+// validate their ranges against.
+func (h *PDFHandler) sourcePDF(jobID string) (string, int, *models.ErrorResponse) {
+	path, errResp := h.sourceJobFile(jobID)
+	if errResp != nil {
+		return "", 0, errResp
+	}
+
+	pageCount, err := pdfgen.PageCount(path)
+	if err != nil {
+		h.store.ReleaseFile(jobID)
+		return "", 0, &models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		}
+	}
+	return path, pageCount, nil
+}
+
+// queuePageJobs creates a job per output and runs them one after another
+// against src, releasing the source job's file once they are done.
+func (h *PDFHandler) queuePageJobs(sourceID, src string, outputs []pageOutput) []*storage.Job {
+	jobs := make([]*storage.Job, 0, len(outputs))
+	ctxs := make([]context.Context, 0, len(outputs))
+	for _, out := range outputs {
+		job := h.store.CreateJob(uuid.New().String(), "", out.filename, nil)
+		h.store.SetSourceJobs(job.ID, []string{sourceID})
+		jobs = append(jobs, job)
+		ctxs = append(ctxs, h.startJob(job.ID))
+	}
+
+	go func() {
+		defer h.store.ReleaseFile(sourceID)
+		for i, job := range jobs {
+			h.processPageJob(ctxs[i], job, src, outputs[i].run)
+		}
+	}()
+
+	return jobs
+}
+
+func (h *PDFHandler) processPageJob(ctx context.Context, job *storage.Job, src string, run func(ctx context.Context, src, dst string) error) {
+	defer h.finishJob(job.ID)
+	h.store.UpdateJobStatus(job.ID, models.JobStatusProcessing, "")
+
+	err := run(ctx, src, job.FilePath)
+
+	h.completeJob(job, "Page Job", nil, err)
+}
+
+func selectedPages(ranges string, pageCount int) ([]int, error) {
+	parsed, err := pdfgen.ParsePageRanges(ranges)
+	if err != nil {
+		return nil, err
+	}
+	return pdfgen.ExpandPageRanges(parsed, pageCount)
+}
+
+// pageOrder expands a reorder's ranges, which must list every page
+// exactly once. Ranges are expanded one at a time so a repeated page is
+// caught before more than pageCount pages are listed.
+func pageOrder(ranges string, pageCount int) ([]int, error) {
+	parsed, err := pdfgen.ParsePageRanges(ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make([]bool, pageCount+1)
+	var order []int
+	for _, r := range parsed {
+		pages, err := pdfgen.ExpandPageRanges([]pdfgen.PageRange{r}, pageCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pages {
+			if seen[p] {
+				return nil, fmt.Errorf("page %d is listed more than once", p)
+			}
+			seen[p] = true
+			order = append(order, p)
+		}
+	}
+	for p := 1; p <= pageCount; p++ {
+		if !seen[p] {
+			return nil, fmt.Errorf("page %d is missing from the order", p)
+		}
+	}
+	return order, nil
+}
+
+// suffixFilename turns "report.pdf" into "report_<suffix>.pdf". An empty
+// filename stays empty so the job store names the file.
+func suffixFilename(filename, suffix string) string {
+	if filename == "" {
+		return ""
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "_" + suffix + ext
+}
+
+// sourceJobFile returns the output of a completed PDF job and keeps it from
+// being cleaned up until the caller releases it with ReleaseFile.
+func (h *PDFHandler) sourceJobFile(jobID string) (string, *models.ErrorResponse) {
+	job, err := h.store.GetJob(jobID)
+	if err != nil {
+		return "", &models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		}
+	}
+	if job.Status != models.JobStatusCompleted {
+		return "", &models.ErrorResponse{
+			Error:   "Conflict",
+			Message: fmt.Sprintf("job %s is %s", jobID, job.Status),
+			Code:    fiber.StatusConflict,
+		}
+	}
+	if job.OutputType != models.OutputPDF {
+		return "", &models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: fmt.Sprintf("job %s produced %s output, not a PDF", jobID, job.OutputType),
+			Code:    fiber.StatusBadRequest,
+		}
+	}
+	if job.Options != nil && job.Options.Encryption != nil {
+		return "", &models.ErrorResponse{
+			Error:   "Conflict",
+			Message: fmt.Sprintf("job %s produced an encrypted PDF", jobID),
+			Code:    fiber.StatusConflict,
+		}
+	}
+	if job.Options != nil && job.Options.Signature != nil {
+		return "", &models.ErrorResponse{
+			Error:   "Conflict",
+			Message: fmt.Sprintf("job %s produced a signed PDF, and changing it would break the signature", jobID),
+			Code:    fiber.StatusConflict,
+		}
+	}
+
+	path, err := h.store.GetFilePath(jobID)
+	if err != nil {
+		return "", &models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		}
+	}
+	return path, nil
+}
+
+// isPDF looks for the %PDF- header, which may be preceded by up to 1024
+// bytes of junk.
+func isPDF(data []byte) bool {
+	return bytes.Contains(data[:min(len(data), 1024+5)], []byte("%PDF-"))
+}
+
+func (h *PDFHandler) completeJob(job *storage.Job, kind string, res *pdfgen.Result, err error) {
+	if res != nil && res.Diagnostics != nil {
+		h.store.SetDiagnostics(job.ID, diagnostics(res.Diagnostics))
+	}
+	if res != nil && res.HTTPStatus != 0 {
+		h.store.SetSourceStatus(job.ID, res.HTTPStatus)
+	}
+	if err == nil && res != nil && res.Format == pdfgen.OutputPDF {
+		source := "options"
+		if res.PageSizeFromCSS {
+			source = "css"
+		}
+		h.store.SetPageSize(job.ID, &models.AppliedPageSize{
+			Name:     res.PageSize.Name,
+			WidthIn:  res.PageSize.Width,
+			HeightIn: res.PageSize.Height,
+			Source:   source,
+		})
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Printf("%s %s cancelled", kind, job.ID)
+		h.store.UpdateJobStatus(job.ID, models.JobStatusCancelled, "job was cancelled")
+	case err != nil:
+		log.Printf("%s %s failed: %v", kind, job.ID, err)
+		h.store.UpdateJobStatus(job.ID, models.JobStatusFailed, err.Error())
+	default:
+		log.Printf("%s %s completed successfully", kind, job.ID)
+		h.store.UpdateJobStatus(job.ID, models.JobStatusCompleted, "")
+	}
+}
+
+func diagnostics(d *pdfgen.Diagnostics) *models.Diagnostics {
+	out := &models.Diagnostics{
+		ConsoleMessages: make([]models.ConsoleMessage, 0, len(d.ConsoleMessages)),
+		Exceptions:      make([]models.PageException, 0, len(d.Exceptions)),
+		FailedRequests:  make([]models.FailedRequest, 0, len(d.FailedRequests)),
+		BlockedRequests: make([]models.BlockedRequest, 0, len(d.BlockedRequests)),
+	}
+	for _, m := range d.ConsoleMessages {
+		out.ConsoleMessages = append(out.ConsoleMessages, models.ConsoleMessage{
+			Level:  m.Level,
+			Text:   m.Text,
+			Source: m.Source,
+			URL:    m.URL,
+			Line:   m.Line,
+		})
+	}
+	for _, e := range d.Exceptions {
+		out.Exceptions = append(out.Exceptions, models.PageException{
+			Message: e.Message,
+			URL:     e.URL,
+			Line:    e.Line,
+			Column:  e.Column,
+		})
+	}
+	for _, f := range d.FailedRequests {
+		out.FailedRequests = append(out.FailedRequests, models.FailedRequest{
+			URL:          f.URL,
+			ResourceType: f.ResourceType,
+			Status:       f.Status,
+			Error:        f.Error,
+		})
+	}
+	for _, b := range d.BlockedRequests {
+		out.BlockedRequests = append(out.BlockedRequests, models.BlockedRequest{
+			URL:          b.URL,
+			ResourceType: b.ResourceType,
+			Reason:       b.Reason,
+		})
+	}
+	return out
+}
+
+// urlCredentials collects the auth settings of a URL request. Credentials
+// embedded in the URL are moved into basic auth so the URL that ends up in
+// the job record is clean.
+func urlCredentials(req *models.GenerateFromURLRequest) (string, *pdfgen.Credentials, error) {
+	targetURL := req.URL
+	basicAuth := req.BasicAuth
+
+	if u, err := url.Parse(req.URL); err == nil && u.User != nil {
+		if basicAuth == nil {
+			password, _ := u.User.Password()
+			basicAuth = &models.BasicAuth{Username: u.User.Username(), Password: password}
+		}
+		u.User = nil
+		targetURL = u.String()
+	}
+
+	if len(req.Headers) == 0 && len(req.Cookies) == 0 && basicAuth == nil {
+		return targetURL, nil, nil
+	}
+
+	creds := &pdfgen.Credentials{Headers: req.Headers}
+	for _, c := range req.Cookies {
+		creds.Cookies = append(creds.Cookies, pdfgen.Cookie{
+			Name:   c.Name,
+			Value:  c.Value,
+			Domain: c.Domain,
+			Path:   c.Path,
+		})
+	}
+	if basicAuth != nil {
+		creds.BasicAuth = &pdfgen.BasicAuth{Username: basicAuth.Username, Password: basicAuth.Password}
+	}
+
+	if err := creds.Validate(); err != nil {
+		return "", nil, err
+	}
+	return targetURL, creds, nil
+}
+
+func convertWatermarks(watermarks []models.Watermark) []pdfgen.Watermark {
+	var out []pdfgen.Watermark
+	for _, w := range watermarks {
+		rotation := 45.0
+		if w.Rotation != nil {
+			rotation = *w.Rotation
+		}
+		out = append(out, pdfgen.Watermark{
+			Text:     w.Text,
+			Image:    w.Image,
+			Font:     w.Font,
+			FontSize: w.FontSize,
+			Color:    w.Color,
+			Opacity:  w.Opacity,
+			Rotation: rotation,
+			Position: w.Position,
+			OffsetX:  w.OffsetX,
+			OffsetY:  w.OffsetY,
+			Scale:    w.Scale,
+			Under:    w.Under,
+			Pages:    w.Pages,
+		})
+	}
+	return out
+}
+
+// convertPrintOptions also rejects signing when the server has no signing
+// certificate, so the request fails now rather than its job later.
+func (h *PDFHandler) convertPrintOptions(opts *models.PrintOptions) (*pdfgen.PrintOptions, error) {
+	if opts == nil {
+		return pdfgen.DefaultPrintOptions(), nil
+	}
+
+	unit, err := pdfgen.ParseUnit(opts.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfOpts := pdfgen.DefaultPrintOptions()
+	pdfOpts.Landscape = opts.Landscape
+	pdfOpts.PrintBackground = opts.PrintBackground
+	pdfOpts.MarginTop = unit.ToInches(opts.MarginTop)
+	pdfOpts.MarginBottom = unit.ToInches(opts.MarginBottom)
+	pdfOpts.MarginLeft = unit.ToInches(opts.MarginLeft)
+	pdfOpts.MarginRight = unit.ToInches(opts.MarginRight)
+	pdfOpts.Scale = opts.Scale
+	pdfOpts.PreferCSSPageSize = opts.PreferCSSPageSize
+	pdfOpts.PageRanges = opts.PageRanges
+	pdfOpts.BaseURL = opts.BaseURL
+	pdfOpts.AcceptStatusCodes = opts.AcceptStatusCodes
+	pdfOpts.Watermarks = convertWatermarks(opts.Watermarks)
+	if e := opts.Encryption; e != nil {
+		permissions := pdfgen.PermitPrint
+		if e.Permissions != nil {
+			if permissions, err = pdfgen.ParsePermissions(e.Permissions); err != nil {
+				return nil, err
+			}
+		}
+		pdfOpts.Encryption = &pdfgen.Encryption{
+			UserPassword:  e.UserPassword,
+			OwnerPassword: e.OwnerPassword,
+			Permissions:   permissions,
+		}
+	}
+	if sig := opts.Signature; sig != nil {
+		if !h.generator.CanSign() {
+			return nil, fmt.Errorf("%w on this server", pdfgen.ErrSigningNotConfigured)
+		}
+		pdfOpts.Signature = &pdfgen.Signature{
+			Reason:      sig.Reason,
+			Location:    sig.Location,
+			ContactInfo: sig.ContactInfo,
+		}
+		if a := sig.Appearance; a != nil {
+			// Signature appearances are laid out in points.
+			pdfOpts.Signature.Appearance = &pdfgen.SignatureAppearance{
+				Page:   a.Page,
+				X:      unit.ToInches(a.X) * 72,
+				Y:      unit.ToInches(a.Y) * 72,
+				Width:  unit.ToInches(a.Width) * 72,
+				Height: unit.ToInches(a.Height) * 72,
+			}
+		}
+	}
+	if m := opts.Metadata; m != nil {
+		pdfOpts.Metadata = &pdfgen.Metadata{
+			Title:    m.Title,
+			Author:   m.Author,
+			Subject:  m.Subject,
+			Keywords: m.Keywords,
+			Creator:  m.Creator,
+			Producer: m.Producer,
+			Custom:   m.Custom,
+		}
+	}
+	if n := opts.Network; n != nil {
+		pdfOpts.Network = &pdfgen.NetworkPolicy{
+			AllowHosts:       n.AllowHosts,
+			BlockHosts:       n.BlockHosts,
+			BlockTrackers:    n.BlockTrackers,
+			Offline:          n.Offline,
+			MaxDownloadBytes: n.MaxDownloadBytes,
+		}
+		for _, t := range n.BlockResourceTypes {
+			pdfOpts.Network.BlockResourceTypes = append(pdfOpts.Network.BlockResourceTypes, pdfgen.ResourceType(strings.ToLower(t)))
+		}
+	}
+
+	if opts.PageWidth != 0 || opts.PageHeight != 0 {
+		if opts.PageSize != "" && !strings.EqualFold(opts.PageSize, "custom") {
+			return nil, fmt.Errorf("page_size %q cannot be combined with page_width/page_height", opts.PageSize)
+		}
+		pdfOpts.PageSize, err = pdfgen.NewPageSize(opts.PageWidth, opts.PageHeight, unit)
+	} else {
+		pdfOpts.PageSize, err = pdfgen.LookupPageSize(opts.PageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pdfOpts.HeaderTemplate = opts.HeaderTemplate
+	pdfOpts.FooterTemplate = opts.FooterTemplate
+	pdfOpts.HeaderSnippet = opts.HeaderSnippet
+	pdfOpts.FooterSnippet = opts.FooterSnippet
+	switch output := opts.OutputType(); output {
+	case models.OutputPDF:
+		if opts.Screenshot != nil {
+			return nil, errors.New("screenshot options require output png, jpeg or webp")
+		}
+	case models.OutputPNG, models.OutputJPEG, models.OutputWebP:
+		shot := opts.Screenshot
+		if shot == nil {
+			shot = &models.Screenshot{}
+		}
+		pdfOpts.Screenshot = &pdfgen.ScreenshotOptions{
+			Format:            pdfgen.OutputFormat(output),
+			Quality:           shot.Quality,
+			FullPage:          shot.FullPage,
+			ViewportWidth:     shot.ViewportWidth,
+			ViewportHeight:    shot.ViewportHeight,
+			DeviceScaleFactor: shot.DeviceScaleFactor,
+		}
+		if shot.Clip != nil {
+			pdfOpts.Screenshot.Clip = &pdfgen.Clip{
+				X:      shot.Clip.X,
+				Y:      shot.Clip.Y,
+				Width:  shot.Clip.Width,
+				Height: shot.Clip.Height,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported output %q", output)
+	}
+
+	pdfOpts.Emulation = pdfgen.Emulation{
+		MediaType:     pdfgen.MediaType(opts.MediaType),
+		ColorScheme:   pdfgen.ColorScheme(opts.ColorScheme),
+		ReducedMotion: pdfgen.ReducedMotion(opts.ReducedMotion),
+	}
+
+	for _, w := range opts.WaitFor {
+		pdfOpts.WaitFor = append(pdfOpts.WaitFor, pdfgen.WaitStrategy{
+			Condition:  pdfgen.WaitCondition(w.Type),
+			IdleTime:   time.Duration(w.IdleMs) * time.Millisecond,
+			Selector:   w.Selector,
+			Expression: w.Expression,
+			Timeout:    time.Duration(w.TimeoutMs) * time.Millisecond,
+			Optional:   w.Optional,
+		})
+	}
+
+	if err := pdfOpts.Validate(); err != nil {
+		return nil, err
 	}
 
-	return pdfOpts
+	return pdfOpts, nil
 }
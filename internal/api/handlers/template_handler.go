@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/api/models"
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/storage"
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/pkg/pdfgen"
+	"github.com/gofiber/fiber/v2"
+)
+
+type TemplateHandler struct {
+	templates *storage.TemplateStore
+	jobs      *storage.JobStore
+}
+
+func NewTemplateHandler(templates *storage.TemplateStore, jobs *storage.JobStore) *TemplateHandler {
+	return &TemplateHandler{
+		templates: templates,
+		jobs:      jobs,
+	}
+}
+
+// @Summary List templates
+// @Description Get all stored templates with their versions and aliases
+// @Tags Templates
+// @Produce json
+// @Success 200 {object} models.ListTemplatesResponse
+// @Router /api/templates [get]
+func (h *TemplateHandler) ListTemplates(c *fiber.Ctx) error {
+	infos := h.templates.List()
+	response := models.ListTemplatesResponse{
+		Templates: make([]models.TemplateResponse, 0, len(infos)),
+	}
+	for _, info := range infos {
+		response.Templates = append(response.Templates, templateResponse(info))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Get a template version
+// @Description Resolve a template reference such as invoice, invoice@3, invoice@latest or invoice@prod
+// @Tags Templates
+// @Produce json
+// @Param ref path string true "Template reference"
+// @Success 200 {object} models.TemplateVersionResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/templates/{ref} [get]
+func (h *TemplateHandler) GetTemplate(c *fiber.Ctx) error {
+	ref, _ := url.PathUnescape(c.Params("ref"))
+	version, err := h.templates.Resolve(ref)
+	if err != nil {
+		return templateStoreError(c, err)
+	}
+	return c.JSON(templateVersionResponse(version, true))
+}
+
+// @Summary Delete a template
+// @Description Delete a template with all of its versions, aliases and assets. Refused while completed jobs were rendered from any of its versions.
+// @Tags Templates
+// @Param name path string true "Template name"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/templates/{name} [delete]
+func (h *TemplateHandler) DeleteTemplate(c *fiber.Ctx) error {
+	name := c.Params("name")
+	// Deleting a version that completed jobs refer to would make those
+	// documents impossible to reproduce.
+	if versions := h.jobs.TemplateVersionsInUse(name); len(versions) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Conflict",
+			Message: fmt.Sprintf("template %s has versions %v used by completed jobs", name, versions),
+			Code:    fiber.StatusConflict,
+		})
+	}
+	if err := h.templates.Delete(name); err != nil {
+		return templateStoreError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// @Summary Create a template version
+// @Description Store a new immutable version of a template, creating the template if needed. Staged assets are copied into the version.
+// @Tags Templates
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param request body models.CreateTemplateVersionRequest true "Template version"
+// @Success 201 {object} models.TemplateVersionResponse
+// @Failure 400 {object} models.TemplateErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/templates/{name}/versions [post]
+func (h *TemplateHandler) CreateVersion(c *fiber.Ctx) error {
+	var req models.CreateTemplateVersionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if req.Template == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "template is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	tmpl := &pdfgen.Template{Text: req.Template, Partials: req.Partials}
+	if err := tmpl.Validate(); err != nil {
+		return templateErrorResponse(c, err)
+	}
+
+	version, err := h.templates.CreateVersion(c.Params("name"), req.Description, req.Template, req.Partials)
+	if err != nil {
+		return templateStoreError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(templateVersionResponse(version, true))
+}
+
+// @Summary List template versions
+// @Description Get every version of a template, oldest first
+// @Tags Templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} models.ListTemplateVersionsResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/templates/{name}/versions [get]
+func (h *TemplateHandler) ListVersions(c *fiber.Ctx) error {
+	name := c.Params("name")
+	versions, err := h.templates.Versions(name)
+	if err != nil {
+		return templateStoreError(c, err)
+	}
+
+	response := models.ListTemplateVersionsResponse{
+		Name:     name,
+		Versions: make([]models.TemplateVersionResponse, 0, len(versions)),
+	}
+	for _, v := range versions {
+		response.Versions = append(response.Versions, templateVersionResponse(v, false))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Set a template alias
+// @Description Point an alias such as prod or draft at a version
+// @Tags Templates
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param alias path string true "Alias"
+// @Param request body models.SetTemplateAliasRequest true "Version to pin"
+// @Success 200 {object} models.TemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/templates/{name}/aliases/{alias} [put]
+func (h *TemplateHandler) SetAlias(c *fiber.Ctx) error {
+	var req models.SetTemplateAliasRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	name := c.Params("name")
+	if err := h.templates.SetAlias(name, c.Params("alias"), req.Version); err != nil {
+		return templateStoreError(c, err)
+	}
+	return h.templateInfo(c, name)
+}
+
+// @Summary Delete a template alias
+// @Tags Templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Param alias path string true "Alias"
+// @Success 200 {object} models.TemplateResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/templates/{name}/aliases/{alias} [delete]
+func (h *TemplateHandler) DeleteAlias(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if err := h.templates.DeleteAlias(name, c.Params("alias")); err != nil {
+		return templateStoreError(c, err)
+	}
+	return h.templateInfo(c, name)
+}
+
+func (h *TemplateHandler) templateInfo(c *fiber.Ctx, name string) error {
+	info, err := h.templates.Get(name)
+	if err != nil {
+		return templateStoreError(c, err)
+	}
+	return c.JSON(templateResponse(info))
+}
+
+// @Summary Upload a template asset
+// @Description Stage an image, font or stylesheet for the next version of a template
+// @Tags Templates
+// @Accept multipart/form-data
+// @Produce json
+// @Param name path string true "Template name"
+// @Param file formData file true "Asset file"
+// @Param path formData string false "Path the template refers to it by, e.g. img/logo.png (default: the file name)"
+// @Success 201 {object} models.TemplateAssetsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/templates/{name}/assets [post]
+func (h *TemplateHandler) UploadAsset(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: "file is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	assetPath := c.FormValue("path")
+	if assetPath == "" {
+		assetPath = fileHeader.Filename
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid upload",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	defer file.Close()
+
+	name := c.Params("name")
+	if _, err := h.templates.PutAsset(name, assetPath, file); err != nil {
+		return templateStoreError(c, err)
+	}
+
+	c.Status(fiber.StatusCreated)
+	return h.stagedAssets(c, name)
+}
+
+// @Summary List staged template assets
+// @Description Get the assets the next version of a template will get
+// @Tags Templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} models.TemplateAssetsResponse
+// @Router /api/templates/{name}/assets [get]
+func (h *TemplateHandler) ListAssets(c *fiber.Ctx) error {
+	return h.stagedAssets(c, c.Params("name"))
+}
+
+// @Summary Delete a staged template asset
+// @Tags Templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Param path path string true "Asset path"
+// @Success 200 {object} models.TemplateAssetsResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/templates/{name}/assets/{path} [delete]
+func (h *TemplateHandler) DeleteAsset(c *fiber.Ctx) error {
+	name := c.Params("name")
+	assetPath, _ := url.PathUnescape(c.Params("*"))
+	if err := h.templates.DeleteAsset(name, assetPath); err != nil {
+		return templateStoreError(c, err)
+	}
+	return h.stagedAssets(c, name)
+}
+
+func (h *TemplateHandler) stagedAssets(c *fiber.Ctx, name string) error {
+	assets, err := h.templates.StagedAssets(name)
+	if err != nil {
+		return templateStoreError(c, err)
+	}
+	if assets == nil {
+		assets = []string{}
+	}
+	return c.JSON(models.TemplateAssetsResponse{
+		Name:   name,
+		Assets: assets,
+	})
+}
+
+func templateStoreError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrTemplateNotFound), errors.Is(err, storage.ErrAssetNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    fiber.StatusNotFound,
+		})
+	case errors.Is(err, storage.ErrInvalidTemplate):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		})
+	}
+}
+
+func templateResponse(info storage.TemplateInfo) models.TemplateResponse {
+	return models.TemplateResponse{
+		Name:      info.Name,
+		Latest:    info.Latest,
+		Versions:  info.Versions,
+		Aliases:   info.Aliases,
+		UpdatedAt: info.UpdatedAt,
+	}
+}
+
+// templateVersionResponse leaves out the template text and partials unless
+// withContent is set, to keep version listings small.
+func templateVersionResponse(v *storage.TemplateVersion, withContent bool) models.TemplateVersionResponse {
+	response := models.TemplateVersionResponse{
+		Name:        v.Name,
+		Version:     v.Version,
+		Description: v.Description,
+		Assets:      v.Assets,
+		CreatedAt:   v.CreatedAt,
+	}
+	if response.Assets == nil {
+		response.Assets = []string{}
+	}
+	if withContent {
+		response.Template = v.Template
+		response.Partials = v.Partials
+	}
+	return response
+}
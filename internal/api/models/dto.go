@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type JobStatus string
 
@@ -9,6 +12,16 @@ const (
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCancelled  JobStatus = "cancelled"
+)
+
+type OutputType string
+
+const (
+	OutputPDF  OutputType = "pdf"
+	OutputPNG  OutputType = "png"
+	OutputJPEG OutputType = "jpeg"
+	OutputWebP OutputType = "webp"
 )
 
 type GeneratePDFRequest struct {
@@ -17,21 +30,249 @@ type GeneratePDFRequest struct {
 	Options  *PrintOptions `json:"options,omitempty"`
 }
 
+// GenerateFromTemplateRequest renders an html/template with data, given
+// either inline (Template and Partials) or as a stored template reference
+// such as "invoice", "invoice@3" or "invoice@prod".
+type GenerateFromTemplateRequest struct {
+	Template    string            `json:"template,omitempty"`
+	Partials    map[string]string `json:"partials,omitempty"`
+	TemplateRef string            `json:"template_ref,omitempty"`
+	Data        json.RawMessage   `json:"data" swaggertype:"object"`
+	Filename    string            `json:"filename"`
+	Options     *PrintOptions     `json:"options,omitempty"`
+}
+
+// GenerateFromURLRequest credentials are used for the render only and are
+// never stored with the job.
 type GenerateFromURLRequest struct {
-	URL      string        `json:"url"`
-	Filename string        `json:"filename"`
-	Options  *PrintOptions `json:"options,omitempty"`
+	URL       string            `json:"url"`
+	Filename  string            `json:"filename"`
+	Options   *PrintOptions     `json:"options,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Cookies   []Cookie          `json:"cookies,omitempty"`
+	BasicAuth *BasicAuth        `json:"basic_auth,omitempty"`
+}
+
+// MergePDFsRequest concatenates parts in order. Each part is either the
+// output of a completed PDF job or an uploaded PDF.
+type MergePDFsRequest struct {
+	Parts    []MergePart `json:"parts"`
+	Filename string      `json:"filename"`
+}
+
+type MergePart struct {
+	JobID    string `json:"job_id,omitempty"`
+	PDF      []byte `json:"pdf,omitempty" swaggertype:"string" format:"base64"`
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// SplitPDFRequest splits a job's PDF into one file per range, such as
+// "1-3, 4-6, 7-", or one file per page when Ranges is empty.
+type SplitPDFRequest struct {
+	Ranges   string `json:"ranges,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type SplitPDFResponse struct {
+	Jobs    []SplitJob `json:"jobs"`
+	Message string     `json:"message"`
+}
+
+type SplitJob struct {
+	JobID     string    `json:"job_id"`
+	Pages     string    `json:"pages"`
+	Status    JobStatus `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExtractPagesRequest keeps the pages Pages selects, in document order.
+type ExtractPagesRequest struct {
+	Pages    string `json:"pages"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// ReorderPagesRequest lists every page exactly once in its new position,
+// e.g. "3, 1-2, 4-" moves page 3 to the front.
+type ReorderPagesRequest struct {
+	Order    string `json:"order"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// RotatePagesRequest rotates Pages, or every page, clockwise by Degrees.
+type RotatePagesRequest struct {
+	Degrees  int    `json:"degrees"`
+	Pages    string `json:"pages,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// WatermarkPDFRequest stamps Watermarks, in order, onto a job's PDF.
+type WatermarkPDFRequest struct {
+	Watermarks []Watermark `json:"watermarks"`
+	Filename   string      `json:"filename,omitempty"`
+}
+
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 type PrintOptions struct {
-	Landscape       bool    `json:"landscape"`
-	PageSize        string  `json:"page_size"`
-	MarginTop       float64 `json:"margin_top"`
-	MarginBottom    float64 `json:"margin_bottom"`
-	MarginLeft      float64 `json:"margin_left"`
-	MarginRight     float64 `json:"margin_right"`
-	PrintBackground bool    `json:"print_background"`
-	Scale           float64 `json:"scale"`
+	Landscape         bool            `json:"landscape"`
+	PageSize          string          `json:"page_size"`
+	PageWidth         float64         `json:"page_width,omitempty"`
+	PageHeight        float64         `json:"page_height,omitempty"`
+	Unit              string          `json:"unit,omitempty"`
+	PreferCSSPageSize bool            `json:"prefer_css_page_size,omitempty"`
+	MarginTop         float64         `json:"margin_top"`
+	MarginBottom      float64         `json:"margin_bottom"`
+	MarginLeft        float64         `json:"margin_left"`
+	MarginRight       float64         `json:"margin_right"`
+	PrintBackground   bool            `json:"print_background"`
+	Scale             float64         `json:"scale"`
+	PageRanges        string          `json:"page_ranges,omitempty"`
+	WaitFor           []WaitCondition `json:"wait_for,omitempty"`
+	HeaderTemplate    string          `json:"header_template,omitempty"`
+	FooterTemplate    string          `json:"footer_template,omitempty"`
+	HeaderSnippet     string          `json:"header_snippet,omitempty"`
+	FooterSnippet     string          `json:"footer_snippet,omitempty"`
+	MediaType         string          `json:"media_type,omitempty"`
+	ColorScheme       string          `json:"color_scheme,omitempty"`
+	ReducedMotion     string          `json:"reduced_motion,omitempty"`
+	Output            OutputType      `json:"output,omitempty"`
+	Screenshot        *Screenshot     `json:"screenshot,omitempty"`
+	BaseURL           string          `json:"base_url,omitempty"`
+	Network           *NetworkPolicy  `json:"network,omitempty"`
+	AcceptStatusCodes []int           `json:"accept_status_codes,omitempty"`
+	Watermarks        []Watermark     `json:"watermarks,omitempty"`
+	Metadata          *Metadata       `json:"metadata,omitempty"`
+	Encryption        *Encryption     `json:"encryption,omitempty"`
+	Signature         *Signature      `json:"signature,omitempty"`
+}
+
+// Signature signs PDF output with the server's configured certificate.
+// Appearance, when set, makes the signature visible; without it the
+// signature is invisible.
+type Signature struct {
+	Reason      string               `json:"reason,omitempty"`
+	Location    string               `json:"location,omitempty"`
+	ContactInfo string               `json:"contact_info,omitempty"`
+	Appearance  *SignatureAppearance `json:"appearance,omitempty"`
+}
+
+// SignatureAppearance places a visible signature box on Page, 0 meaning
+// the last one. X and Y are its top-left corner from the page's top-left,
+// in the request's unit like the size.
+type SignatureAppearance struct {
+	Page   int     `json:"page,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Encryption password-protects PDF output with AES-256. Permissions lists
+// what a reader who opens it with the user password may do: print, copy,
+// modify and annotate. It defaults to print only; [] allows nothing.
+type Encryption struct {
+	UserPassword  string   `json:"user_password,omitempty"`
+	OwnerPassword string   `json:"owner_password"`
+	Permissions   []string `json:"permissions,omitempty"`
+}
+
+// Watermark is a text or PNG/JPEG image stamped over, or with Under behind,
+// every page Pages selects. Rotation defaults to 45 degrees, the diagonal
+// "DRAFT" look; set it to 0 for horizontal text.
+type Watermark struct {
+	Text     string   `json:"text,omitempty"`
+	Image    []byte   `json:"image,omitempty" swaggertype:"string" format:"base64"`
+	Font     string   `json:"font,omitempty"`
+	FontSize int      `json:"font_size,omitempty"`
+	Color    string   `json:"color,omitempty"`
+	Opacity  float64  `json:"opacity,omitempty"`
+	Rotation *float64 `json:"rotation,omitempty"`
+	Position string   `json:"position,omitempty"`
+	OffsetX  float64  `json:"offset_x,omitempty"`
+	OffsetY  float64  `json:"offset_y,omitempty"`
+	Scale    float64  `json:"scale,omitempty"`
+	Under    bool     `json:"under,omitempty"`
+	Pages    string   `json:"pages,omitempty"`
+}
+
+// Metadata is written into the PDF's document information and XMP
+// metadata. Title defaults to the document's <title>.
+type Metadata struct {
+	Title    string            `json:"title,omitempty"`
+	Author   string            `json:"author,omitempty"`
+	Subject  string            `json:"subject,omitempty"`
+	Keywords []string          `json:"keywords,omitempty"`
+	Creator  string            `json:"creator,omitempty"`
+	Producer string            `json:"producer,omitempty"`
+	Custom   map[string]string `json:"custom,omitempty"`
+}
+
+// NetworkPolicy restricts what a render may fetch. Host patterns are a host
+// name or "*.example.com" for a domain and its subdomains.
+type NetworkPolicy struct {
+	AllowHosts         []string `json:"allow_hosts,omitempty"`
+	BlockHosts         []string `json:"block_hosts,omitempty"`
+	BlockResourceTypes []string `json:"block_resource_types,omitempty"`
+	BlockTrackers      bool     `json:"block_trackers,omitempty"`
+	Offline            bool     `json:"offline,omitempty"`
+	MaxDownloadBytes   int64    `json:"max_download_bytes,omitempty"`
+}
+
+// OutputType returns the requested output, defaulting to PDF.
+func (o *PrintOptions) OutputType() OutputType {
+	if o == nil || o.Output == "" {
+		return OutputPDF
+	}
+	return o.Output
+}
+
+// Redacted returns a copy of o that is safe to keep with a job: encryption
+// passwords are cleared.
+func (o *PrintOptions) Redacted() *PrintOptions {
+	if o == nil || o.Encryption == nil {
+		return o
+	}
+	redacted := *o
+	redacted.Encryption = &Encryption{Permissions: o.Encryption.Permissions}
+	return &redacted
+}
+
+// Screenshot configures png, jpeg and webp output.
+type Screenshot struct {
+	Quality           int     `json:"quality,omitempty"`
+	FullPage          bool    `json:"full_page"`
+	Clip              *Clip   `json:"clip,omitempty"`
+	ViewportWidth     int     `json:"viewport_width,omitempty"`
+	ViewportHeight    int     `json:"viewport_height,omitempty"`
+	DeviceScaleFactor float64 `json:"device_scale_factor,omitempty"`
+}
+
+type Clip struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// WaitCondition delays printing until the page is ready. Type is one of
+// network_idle, fonts_ready, selector, expression or pdf_ready.
+type WaitCondition struct {
+	Type       string `json:"type"`
+	IdleMs     int    `json:"idle_ms,omitempty"`
+	Selector   string `json:"selector,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+	Optional   bool   `json:"optional,omitempty"`
 }
 
 type GeneratePDFResponse struct {
@@ -42,15 +283,120 @@ type GeneratePDFResponse struct {
 }
 
 type JobStatusResponse struct {
-	JobID        string     `json:"job_id"`
-	Status       JobStatus  `json:"status"`
-	Filename     string     `json:"filename,omitempty"`
-	FileSize     int64      `json:"file_size,omitempty"`
-	DownloadURL  string     `json:"download_url,omitempty"`
-	ErrorMessage string     `json:"error_message,omitempty"`
-	Progress     int        `json:"progress"`
-	CreatedAt    time.Time  `json:"created_at"`
-	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	JobID           string           `json:"job_id"`
+	Status          JobStatus        `json:"status"`
+	Filename        string           `json:"filename,omitempty"`
+	FileSize        int64            `json:"file_size,omitempty"`
+	DownloadURL     string           `json:"download_url,omitempty"`
+	ErrorMessage    string           `json:"error_message,omitempty"`
+	Progress        int              `json:"progress"`
+	CreatedAt       time.Time        `json:"created_at"`
+	CompletedAt     *time.Time       `json:"completed_at,omitempty"`
+	OutputType      OutputType       `json:"output_type"`
+	AppliedPageSize *AppliedPageSize `json:"applied_page_size,omitempty"`
+	SourceStatus    int              `json:"source_http_status,omitempty"`
+	Template        *TemplateRef     `json:"template,omitempty"`
+	SourceJobs      []string         `json:"source_jobs,omitempty"`
+	Encrypted       bool             `json:"encrypted,omitempty"`
+	Signed          bool             `json:"signed,omitempty"`
+}
+
+// TemplateRef is the stored template version a job was rendered from.
+type TemplateRef struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// Diagnostics is what the browser reported while rendering a job.
+type Diagnostics struct {
+	ConsoleMessages []ConsoleMessage `json:"console_messages"`
+	Exceptions      []PageException  `json:"exceptions"`
+	FailedRequests  []FailedRequest  `json:"failed_requests"`
+	BlockedRequests []BlockedRequest `json:"blocked_requests"`
+}
+
+type DiagnosticsResponse struct {
+	JobID  string    `json:"job_id"`
+	Status JobStatus `json:"status"`
+	Diagnostics
+}
+
+type ConsoleMessage struct {
+	Level  string `json:"level"`
+	Text   string `json:"text"`
+	Source string `json:"source"`
+	URL    string `json:"url,omitempty"`
+	Line   int64  `json:"line,omitempty"`
+}
+
+type PageException struct {
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	Line    int64  `json:"line,omitempty"`
+	Column  int64  `json:"column,omitempty"`
+}
+
+type FailedRequest struct {
+	URL          string `json:"url"`
+	ResourceType string `json:"resource_type"`
+	Status       int64  `json:"status,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type BlockedRequest struct {
+	URL          string `json:"url"`
+	ResourceType string `json:"resource_type"`
+	Reason       string `json:"reason"`
+}
+
+// AppliedPageSize is the size of the first page of the output. Source is
+// "css" when the document's @page rule won over the requested size.
+type AppliedPageSize struct {
+	Name     string  `json:"name"`
+	WidthIn  float64 `json:"width_in"`
+	HeightIn float64 `json:"height_in"`
+	Source   string  `json:"source"`
+}
+
+// VerifyPDFResponse reports the signatures in an uploaded PDF. Valid is
+// true only when it is signed and every signature is valid.
+type VerifyPDFResponse struct {
+	Signed     bool              `json:"signed"`
+	Valid      bool              `json:"valid"`
+	Signatures []SignatureResult `json:"signatures"`
+}
+
+// SignatureResult is one signature's verdict. Status is valid, invalid or
+// unknown, with Detail saying why.
+type SignatureResult struct {
+	Field             string             `json:"field"`
+	Status            string             `json:"status"`
+	Detail            string             `json:"detail"`
+	Modified          bool               `json:"document_modified"`
+	Trusted           bool               `json:"trusted"`
+	RevocationChecked bool               `json:"revocation_checked"`
+	SignerName        string             `json:"signer_name,omitempty"`
+	SigningTime       *time.Time         `json:"signing_time,omitempty"`
+	Reason            string             `json:"reason,omitempty"`
+	Location          string             `json:"location,omitempty"`
+	ContactInfo       string             `json:"contact_info,omitempty"`
+	SubFilter         string             `json:"sub_filter,omitempty"`
+	PAdES             string             `json:"pades_level,omitempty"`
+	Certified         bool               `json:"certified,omitempty"`
+	Visible           bool               `json:"visible"`
+	Page              int                `json:"page,omitempty"`
+	Certificate       *SignerCertificate `json:"certificate,omitempty"`
+	Problems          []string           `json:"problems,omitempty"`
+}
+
+type SignerCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	SelfSigned   bool      `json:"self_signed,omitempty"`
+	Expired      bool      `json:"expired,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -59,6 +405,15 @@ type ErrorResponse struct {
 	Code    int    `json:"code"`
 }
 
+// TemplateErrorResponse points at the template position that failed.
+// Column is omitted when only the line is known.
+type TemplateErrorResponse struct {
+	ErrorResponse
+	Template string `json:"template"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+}
+
 type HealthResponse struct {
 	Status    string            `json:"status"`
 	Version   string            `json:"version"`
@@ -73,3 +428,70 @@ type ListJobsResponse struct {
 	PageSize   int                 `json:"page_size"`
 	TotalPages int                 `json:"total_pages"`
 }
+
+type ListSnippetsResponse struct {
+	Snippets []string `json:"snippets"`
+}
+
+type FontResponse struct {
+	Family string `json:"family"`
+	Weight int    `json:"weight"`
+	Style  string `json:"style"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+}
+
+type ListFontsResponse struct {
+	Fonts []FontResponse `json:"fonts"`
+}
+
+type MirrorGoogleFontsRequest struct {
+	URL string `json:"url"`
+}
+
+type MirrorGoogleFontsResponse struct {
+	URL   string `json:"url"`
+	Files int    `json:"files"`
+}
+
+type CreateTemplateVersionRequest struct {
+	Template    string            `json:"template"`
+	Partials    map[string]string `json:"partials,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+type TemplateVersionResponse struct {
+	Name        string            `json:"name"`
+	Version     int               `json:"version"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template,omitempty"`
+	Partials    map[string]string `json:"partials,omitempty"`
+	Assets      []string          `json:"assets"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type TemplateResponse struct {
+	Name      string         `json:"name"`
+	Latest    int            `json:"latest"`
+	Versions  []int          `json:"versions"`
+	Aliases   map[string]int `json:"aliases"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type ListTemplatesResponse struct {
+	Templates []TemplateResponse `json:"templates"`
+}
+
+type ListTemplateVersionsResponse struct {
+	Name     string                    `json:"name"`
+	Versions []TemplateVersionResponse `json:"versions"`
+}
+
+type SetTemplateAliasRequest struct {
+	Version int `json:"version"`
+}
+
+type TemplateAssetsResponse struct {
+	Name   string   `json:"name"`
+	Assets []string `json:"assets"`
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/internal/api/models"
+	"github.com/HassanAlphaSquad/golang-pdf-generation-poc/pkg/pdfgen"
 )
 
 type Job struct {
@@ -24,6 +26,12 @@ type Job struct {
 	CreatedAt    time.Time
 	CompletedAt  *time.Time
 	Options      *models.PrintOptions
+	OutputType   models.OutputType
+	PageSize     *models.AppliedPageSize
+	Diagnostics  *models.Diagnostics
+	SourceStatus int
+	Template     *models.TemplateRef
+	SourceJobs   []string
 }
 
 type JobStore struct {
@@ -53,24 +61,27 @@ func (s *JobStore) CreateJob(id, html, filename string, opts *models.PrintOption
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().Format("20060102_150405")
+	output := opts.OutputType()
+	outputExt := pdfgen.OutputFormat(output).Extension()
 
 	if filename == "" {
-		filename = fmt.Sprintf("%s_%s.pdf", id, timestamp)
+		filename = fmt.Sprintf("%s_%s%s", id, timestamp, outputExt)
 	} else {
 		ext := filepath.Ext(filename)
 		nameWithoutExt := filename[:len(filename)-len(ext)]
-		filename = fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext)
+		filename = fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, outputExt)
 	}
 
 	job := &Job{
-		ID:        id,
-		Status:    models.JobStatusPending,
-		HTML:      html,
-		Filename:  filename,
-		FilePath:  filepath.Join(s.outputDir, filename),
-		Progress:  0,
-		CreatedAt: time.Now(),
-		Options:   opts,
+		ID:         id,
+		Status:     models.JobStatusPending,
+		HTML:       html,
+		Filename:   filename,
+		FilePath:   filepath.Join(s.outputDir, filename),
+		Progress:   0,
+		CreatedAt:  time.Now(),
+		Options:    opts.Redacted(),
+		OutputType: output,
 	}
 
 	s.jobs[id] = job
@@ -144,7 +155,7 @@ func (s *JobStore) UpdateJobStatus(id string, status models.JobStatus, errorMsg
 		if info, err := os.Stat(job.FilePath); err == nil {
 			job.FileSize = info.Size()
 		}
-	case models.JobStatusFailed:
+	case models.JobStatusFailed, models.JobStatusCancelled:
 		job.Progress = 0
 		now := time.Now()
 		job.CompletedAt = &now
@@ -153,6 +164,93 @@ func (s *JobStore) UpdateJobStatus(id string, status models.JobStatus, errorMsg
 	return nil
 }
 
+func (s *JobStore) SetPageSize(id string, size *models.AppliedPageSize) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	job.PageSize = size
+	return nil
+}
+
+func (s *JobStore) SetDiagnostics(id string, diagnostics *models.Diagnostics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	job.Diagnostics = diagnostics
+	return nil
+}
+
+// SetTemplate records the stored template version a job was rendered from.
+func (s *JobStore) SetTemplate(id string, ref *models.TemplateRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	job.Template = ref
+	return nil
+}
+
+// TemplateVersionsInUse returns the versions of the named template that
+// completed jobs were rendered from, in ascending order.
+func (s *JobStore) TemplateVersionsInUse(name string) []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var versions []int
+	for _, job := range s.jobs {
+		if job.Status != models.JobStatusCompleted || job.Template == nil || job.Template.Name != name {
+			continue
+		}
+		if !slices.Contains(versions, job.Template.Version) {
+			versions = append(versions, job.Template.Version)
+		}
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+// SetSourceJobs records the jobs whose output a job was built from.
+func (s *JobStore) SetSourceJobs(id string, sourceJobs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	job.SourceJobs = sourceJobs
+	return nil
+}
+
+// SetSourceStatus records the HTTP status the source URL answered with.
+func (s *JobStore) SetSourceStatus(id string, status int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	job.SourceStatus = status
+	return nil
+}
+
 func (s *JobStore) ListJobs(page, pageSize int) ([]*Job, int) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -229,6 +327,7 @@ func (s *JobStore) GetStats() map[string]int {
 		"processing": 0,
 		"completed":  0,
 		"failed":     0,
+		"cancelled":  0,
 		"batches":    len(s.batches),
 	}
 
@@ -242,6 +341,8 @@ func (s *JobStore) GetStats() map[string]int {
 			stats["completed"]++
 		case models.JobStatusFailed:
 			stats["failed"]++
+		case models.JobStatusCancelled:
+			stats["cancelled"]++
 		}
 	}
 
@@ -0,0 +1,574 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrAssetNotFound    = errors.New("asset not found")
+	ErrInvalidTemplate  = errors.New("invalid template request")
+)
+
+// LatestAlias always points at the highest version and cannot be set.
+const LatestAlias = "latest"
+
+var (
+	templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	assetSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+)
+
+// TemplateVersion is an immutable snapshot of a template: its text,
+// partials and the assets that were staged when it was created.
+type TemplateVersion struct {
+	Name        string            `json:"name"`
+	Version     int               `json:"version"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template"`
+	Partials    map[string]string `json:"partials,omitempty"`
+	Assets      []string          `json:"assets,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+
+	// AssetsDir is where this version's assets live on disk.
+	AssetsDir string `json:"-"`
+}
+
+// TemplateInfo summarises a named template.
+type TemplateInfo struct {
+	Name      string
+	Latest    int
+	Versions  []int
+	Aliases   map[string]int
+	UpdatedAt time.Time
+}
+
+type templateEntry struct {
+	versions []*TemplateVersion // ordered by version
+	aliases  map[string]int
+}
+
+// TemplateStore keeps named, versioned templates on disk. Assets are staged
+// per template and copied into each new version, so a version renders the
+// same way no matter what is uploaded later.
+//
+// Layout: <dir>/<name>/assets/ holds staged assets, <dir>/<name>/aliases.json
+// the aliases and <dir>/<name>/v<N>/ each version. A deleted template
+// leaves <dir>/<name>/last_version behind, so a template created again
+// under its name never reuses a version number.
+type TemplateStore struct {
+	dir       string
+	mu        sync.RWMutex
+	templates map[string]*templateEntry
+	deleted   map[string]int // the last version of each deleted template
+}
+
+func NewTemplateStore(dir string) (*TemplateStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create template directory: %w", err)
+	}
+
+	s := &TemplateStore{dir: dir, templates: make(map[string]*templateEntry), deleted: make(map[string]int)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !templateNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		entry, err := s.load(e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template %s: %w", e.Name(), err)
+		}
+		if len(entry.versions) > 0 {
+			s.templates[e.Name()] = entry
+		}
+		last, err := s.readLastVersion(e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template %s: %w", e.Name(), err)
+		}
+		if last > 0 {
+			s.deleted[e.Name()] = last
+		}
+	}
+	return s, nil
+}
+
+func (s *TemplateStore) lastVersionPath(name string) string {
+	return filepath.Join(s.dir, name, "last_version")
+}
+
+func (s *TemplateStore) readLastVersion(name string) (int, error) {
+	data, err := os.ReadFile(s.lastVersionPath(name))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func (s *TemplateStore) load(name string) (*templateEntry, error) {
+	entry := &templateEntry{aliases: make(map[string]int)}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name, "aliases.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entry.aliases); err != nil {
+			return nil, err
+		}
+	}
+
+	dirs, err := os.ReadDir(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		n, err := strconv.Atoi(strings.TrimPrefix(d.Name(), "v"))
+		if !d.IsDir() || !strings.HasPrefix(d.Name(), "v") || err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name, d.Name(), "version.json"))
+		if err != nil {
+			return nil, err
+		}
+		var v TemplateVersion
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		v.Name, v.Version = name, n
+		v.AssetsDir = filepath.Join(s.dir, name, d.Name(), "assets")
+		entry.versions = append(entry.versions, &v)
+	}
+	sort.Slice(entry.versions, func(i, j int) bool {
+		return entry.versions[i].Version < entry.versions[j].Version
+	})
+	return entry, nil
+}
+
+func validateTemplateName(name string) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: template name must be lowercase letters, digits, '-' or '_'", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// ParseTemplateRef splits "name", "name@3", "name@latest" or "name@alias"
+// into the name and the version selector, which defaults to latest.
+func ParseTemplateRef(ref string) (name, selector string) {
+	name, selector, _ = strings.Cut(ref, "@")
+	if selector == "" {
+		selector = LatestAlias
+	}
+	return name, selector
+}
+
+// Resolve returns the version ref points at.
+func (s *TemplateStore) Resolve(ref string) (*TemplateVersion, error) {
+	name, selector := ParseTemplateRef(ref)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	version, ok := entry.aliases[selector]
+	switch {
+	case selector == LatestAlias:
+		version = entry.versions[len(entry.versions)-1].Version
+	case !ok:
+		n, err := strconv.Atoi(selector)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s has no alias %q", ErrTemplateNotFound, name, selector)
+		}
+		version = n
+	}
+
+	for _, v := range entry.versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s has no version %d", ErrTemplateNotFound, name, version)
+}
+
+// List returns every template, sorted by name.
+func (s *TemplateStore) List() []TemplateInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]TemplateInfo, 0, len(s.templates))
+	for name, entry := range s.templates {
+		infos = append(infos, entry.info(name))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func (s *TemplateStore) Get(name string) (TemplateInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return TemplateInfo{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return entry.info(name), nil
+}
+
+func (e *templateEntry) info(name string) TemplateInfo {
+	info := TemplateInfo{
+		Name:    name,
+		Aliases: make(map[string]int, len(e.aliases)),
+	}
+	for alias, version := range e.aliases {
+		info.Aliases[alias] = version
+	}
+	for _, v := range e.versions {
+		info.Versions = append(info.Versions, v.Version)
+	}
+	latest := e.versions[len(e.versions)-1]
+	info.Latest = latest.Version
+	info.UpdatedAt = latest.CreatedAt
+	return info
+}
+
+// Versions returns the versions of name, oldest first.
+func (s *TemplateStore) Versions(name string) ([]*TemplateVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return append([]*TemplateVersion(nil), entry.versions...), nil
+}
+
+// CreateVersion stores a new version of name, creating the template if it
+// does not exist yet. The currently staged assets are copied into it.
+func (s *TemplateStore) CreateVersion(name, description, text string, partials map[string]string) (*TemplateVersion, error) {
+	if err := validateTemplateName(name); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.templates[name]
+	next := s.deleted[name] + 1
+	if entry != nil {
+		next = max(next, entry.versions[len(entry.versions)-1].Version+1)
+	}
+
+	versionDir := filepath.Join(s.dir, name, "v"+strconv.Itoa(next))
+	v := &TemplateVersion{
+		Name:        name,
+		Version:     next,
+		Description: description,
+		Template:    text,
+		Partials:    partials,
+		CreatedAt:   time.Now(),
+		AssetsDir:   filepath.Join(versionDir, "assets"),
+	}
+
+	if err := os.MkdirAll(v.AssetsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create template version: %w", err)
+	}
+	assets, err := copyTree(s.stagedDir(name), v.AssetsDir)
+	if err != nil {
+		os.RemoveAll(versionDir)
+		return nil, fmt.Errorf("failed to snapshot assets: %w", err)
+	}
+	v.Assets = assets
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		os.RemoveAll(versionDir)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, "version.json"), data, 0644); err != nil {
+		os.RemoveAll(versionDir)
+		return nil, fmt.Errorf("failed to write template version: %w", err)
+	}
+
+	if entry == nil {
+		entry = &templateEntry{aliases: make(map[string]int)}
+		s.templates[name] = entry
+	}
+	entry.versions = append(entry.versions, v)
+	return v, nil
+}
+
+// Delete removes a template with all of its versions, aliases and assets.
+// Only its last version number is kept.
+func (s *TemplateStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	last := max(s.deleted[name], entry.versions[len(entry.versions)-1].Version)
+	if err := os.WriteFile(s.lastVersionPath(name), []byte(strconv.Itoa(last)), 0644); err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	delete(s.templates, name)
+	s.deleted[name] = last
+
+	files, err := os.ReadDir(filepath.Join(s.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	for _, f := range files {
+		if f.Name() == "last_version" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, name, f.Name())); err != nil {
+			return fmt.Errorf("failed to delete template: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetAlias points alias at an existing version of name.
+func (s *TemplateStore) SetAlias(name, alias string, version int) error {
+	if alias == LatestAlias || !templateNamePattern.MatchString(alias) {
+		return fmt.Errorf("%w: alias must be lowercase letters, digits, '-' or '_' and not %q", ErrInvalidTemplate, LatestAlias)
+	}
+	if _, err := strconv.Atoi(alias); err == nil {
+		return fmt.Errorf("%w: alias cannot be a number", ErrInvalidTemplate)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	found := false
+	for _, v := range entry.versions {
+		found = found || v.Version == version
+	}
+	if !found {
+		return fmt.Errorf("%w: %s has no version %d", ErrTemplateNotFound, name, version)
+	}
+
+	aliases := make(map[string]int, len(entry.aliases)+1)
+	for a, v := range entry.aliases {
+		aliases[a] = v
+	}
+	aliases[alias] = version
+	if err := s.writeAliases(name, aliases); err != nil {
+		return err
+	}
+	entry.aliases = aliases
+	return nil
+}
+
+func (s *TemplateStore) DeleteAlias(name, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.templates[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if _, ok := entry.aliases[alias]; !ok {
+		return fmt.Errorf("%w: %s has no alias %q", ErrTemplateNotFound, name, alias)
+	}
+
+	aliases := make(map[string]int, len(entry.aliases))
+	for a, v := range entry.aliases {
+		if a != alias {
+			aliases[a] = v
+		}
+	}
+	if err := s.writeAliases(name, aliases); err != nil {
+		return err
+	}
+	entry.aliases = aliases
+	return nil
+}
+
+func (s *TemplateStore) writeAliases(name string, aliases map[string]int) error {
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, name, "aliases.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
+
+func (s *TemplateStore) stagedDir(name string) string {
+	return filepath.Join(s.dir, name, "assets")
+}
+
+// cleanAssetPath validates a slash-separated asset path such as
+// "img/logo.png" and returns it in clean form.
+func cleanAssetPath(p string) (string, error) {
+	p = path.Clean(strings.TrimPrefix(p, "/"))
+	for _, segment := range strings.Split(p, "/") {
+		if !assetSegmentPattern.MatchString(segment) {
+			return "", fmt.Errorf("%w: asset path %q", ErrInvalidTemplate, p)
+		}
+	}
+	return p, nil
+}
+
+// PutAsset stages an asset for the next version of name. The template does
+// not need to have a version yet.
+func (s *TemplateStore) PutAsset(name, assetPath string, r io.Reader) (string, error) {
+	if err := validateTemplateName(name); err != nil {
+		return "", err
+	}
+	assetPath, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	// The upload is written out before taking the lock, which only covers
+	// moving it into place.
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := filepath.Join(s.stagedDir(name), filepath.FromSlash(assetPath))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	return assetPath, nil
+}
+
+// StagedAssets lists the assets the next version of name will get.
+func (s *TemplateStore) StagedAssets(name string) ([]string, error) {
+	if err := validateTemplateName(name); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return listTree(s.stagedDir(name))
+}
+
+func (s *TemplateStore) DeleteAsset(name, assetPath string) error {
+	if err := validateTemplateName(name); err != nil {
+		return err
+	}
+	assetPath, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = os.Remove(filepath.Join(s.stagedDir(name), filepath.FromSlash(assetPath)))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrAssetNotFound, assetPath)
+	}
+	return err
+}
+
+func listTree(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// copyTree copies the regular files under src into dst and returns their
+// slash-separated relative paths. A missing src copies nothing.
+func copyTree(src, dst string) ([]string, error) {
+	files, err := listTree(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range files {
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := copyFile(filepath.Join(src, filepath.FromSlash(rel)), target); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
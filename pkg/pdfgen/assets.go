@@ -0,0 +1,149 @@
+package pdfgen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+var ErrInvalidBaseURL = errors.New("base URL must be an absolute http or https URL")
+
+// assetOrigin is the made-up origin raw HTML is served from when it has an
+// asset directory but no base URL. The .invalid TLD never resolves, so
+// nothing under it can leak to the network.
+const assetOrigin = "http://assets.pdfgen.invalid"
+
+const maxAssetSize = 50 << 20
+
+var assetContentTypes = map[string]string{
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".svg":   "image/svg+xml",
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+}
+
+func validateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidBaseURL, raw)
+	}
+	return nil
+}
+
+// documentURL is the address raw HTML is loaded under so relative links
+// resolve. It is empty when neither a base URL nor an asset directory is
+// set, in which case the HTML goes into about:blank as before.
+func documentURL(baseURL, assetDir string) string {
+	if baseURL != "" {
+		// Match the form Chrome reports in paused requests.
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return baseURL
+		}
+		u.Fragment = ""
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		return u.String()
+	}
+	if assetDir != "" {
+		return assetOrigin + "/"
+	}
+	return ""
+}
+
+// serveDocument answers the navigation to docURL with html itself, so the
+// page gets a real origin without anything being fetched.
+func serveDocument(docURL, html string) requestRule {
+	return func(ev *fetch.EventRequestPaused) *requestDecision {
+		if ev.ResourceType != network.ResourceTypeDocument || ev.Request.URL != docURL {
+			return nil
+		}
+		return &requestDecision{
+			Status: http.StatusOK,
+			Headers: []*fetch.HeaderEntry{
+				{Name: "Content-Type", Value: "text/html; charset=utf-8"},
+			},
+			Body: []byte(html),
+		}
+	}
+}
+
+// serveAssets answers requests under the document's origin from root.
+// Paths are resolved inside the os.Root so "../" and symlinks cannot escape
+// it. Under assetOrigin a missing file is a 404; under a real base URL the
+// request falls through to the network.
+func serveAssets(docURL string, root *os.Root) requestRule {
+	prefix := origin(docURL)
+	synthetic := prefix == assetOrigin
+
+	return func(ev *fetch.EventRequestPaused) *requestDecision {
+		u, err := url.Parse(ev.Request.URL)
+		if err != nil || origin(ev.Request.URL) != prefix {
+			return nil
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+		if name == "" {
+			return nil
+		}
+
+		body, err := readAsset(root, name)
+		if err != nil {
+			if synthetic {
+				return &requestDecision{Status: http.StatusNotFound}
+			}
+			return nil
+		}
+
+		return &requestDecision{
+			Status: http.StatusOK,
+			Headers: []*fetch.HeaderEntry{
+				{Name: "Content-Type", Value: assetContentType(name)},
+				{Name: "Access-Control-Allow-Origin", Value: "*"},
+			},
+			Body: body,
+		}
+	}
+}
+
+func readAsset(root *os.Root, name string) ([]byte, error) {
+	f, err := root.Open(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() || info.Size() > maxAssetSize {
+		return nil, os.ErrNotExist
+	}
+	return io.ReadAll(f)
+}
+
+func assetContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := assetContentTypes[ext]; ok {
+		return ct
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
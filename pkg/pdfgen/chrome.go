@@ -0,0 +1,128 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidChromeConfig = errors.New("invalid chrome configuration")
+
+// ChromeConfig controls how the Generator gets its browsers: either by
+// launching Chrome itself or, with RemoteURL, by attaching to one that is
+// already running, such as a sidecar container. Launch options cannot be
+// combined with RemoteURL.
+type ChromeConfig struct {
+	ExecPath string
+
+	// Flags are extra command-line switches, written "name" or
+	// "name=value" without the leading dashes. "name=false" drops one of
+	// the defaults, such as "no-sandbox=false".
+	Flags []string
+
+	// UserDataDir is Chrome's profile directory. With more than one
+	// browser in the pool, each gets its own numbered subdirectory.
+	UserDataDir  string
+	ProxyServer  string
+	WindowWidth  int
+	WindowHeight int
+	UserAgent    string
+
+	// RemoteURL is the DevTools endpoint of a running Chrome, either the
+	// ws:// debugger URL or the http:// address it listens on.
+	RemoteURL string
+}
+
+func (c ChromeConfig) Validate() error {
+	if c.RemoteURL != "" {
+		u, err := url.Parse(c.RemoteURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("%w: remote URL %q", ErrInvalidChromeConfig, c.RemoteURL)
+		}
+		switch u.Scheme {
+		case "ws", "wss", "http", "https":
+		default:
+			return fmt.Errorf("%w: remote URL must be ws, wss, http or https", ErrInvalidChromeConfig)
+		}
+		if c.ExecPath != "" || len(c.Flags) > 0 || c.UserDataDir != "" || c.ProxyServer != "" || c.WindowWidth != 0 || c.WindowHeight != 0 {
+			return fmt.Errorf("%w: launch options cannot be used with a remote browser", ErrInvalidChromeConfig)
+		}
+	}
+	for _, flag := range c.Flags {
+		name, _, _ := strings.Cut(flag, "=")
+		if name == "" || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("%w: flag %q", ErrInvalidChromeConfig, flag)
+		}
+	}
+	if c.WindowWidth < 0 || c.WindowHeight < 0 || (c.WindowWidth == 0) != (c.WindowHeight == 0) {
+		return fmt.Errorf("%w: window size needs a positive width and height", ErrInvalidChromeConfig)
+	}
+	return nil
+}
+
+func (c ChromeConfig) remote() bool {
+	return c.RemoteURL != ""
+}
+
+// newAllocator returns the allocator for the browser in pool slot index.
+// A launched browser sends its traffic through guardProxy when one is
+// given, in place of ProxyServer.
+func (c ChromeConfig) newAllocator(index int, guardProxy string) (context.Context, context.CancelFunc) {
+	if c.remote() {
+		return chromedp.NewRemoteAllocator(context.Background(), c.RemoteURL)
+	}
+	return chromedp.NewExecAllocator(context.Background(), c.execOptions(index, guardProxy)...)
+}
+
+func (c ChromeConfig) execOptions(index int, guardProxy string) []chromedp.ExecAllocatorOption {
+	options := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.DisableGPU,
+		chromedp.NoSandbox,
+		chromedp.Flag("disable-dev-shm-usage", true),
+	)
+
+	if c.ExecPath != "" {
+		options = append(options, chromedp.ExecPath(c.ExecPath))
+	}
+	if c.UserDataDir != "" {
+		options = append(options, chromedp.UserDataDir(filepath.Join(c.UserDataDir, "browser-"+strconv.Itoa(index))))
+	}
+	if guardProxy != "" {
+		// Loopback is normally exempt from proxying, and WebRTC would
+		// otherwise send UDP around the proxy.
+		options = append(options,
+			chromedp.ProxyServer(guardProxy),
+			chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+			chromedp.Flag("force-webrtc-ip-handling-policy", "disable_non_proxied_udp"),
+		)
+	} else if c.ProxyServer != "" {
+		options = append(options, chromedp.ProxyServer(c.ProxyServer))
+	}
+	if c.WindowWidth > 0 {
+		options = append(options, chromedp.WindowSize(c.WindowWidth, c.WindowHeight))
+	}
+	if c.UserAgent != "" {
+		options = append(options, chromedp.UserAgent(c.UserAgent))
+	}
+	for _, flag := range c.Flags {
+		options = append(options, parseFlag(flag))
+	}
+	return options
+}
+
+func parseFlag(flag string) chromedp.ExecAllocatorOption {
+	name, value, ok := strings.Cut(flag, "=")
+	if !ok {
+		return chromedp.Flag(name, true)
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return chromedp.Flag(name, b)
+	}
+	return chromedp.Flag(name, value)
+}
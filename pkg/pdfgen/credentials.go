@@ -0,0 +1,104 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type Cookie struct {
+	Name   string
+	Value  string
+	Domain string // defaults to the host of the URL being rendered
+	Path   string
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Credentials let a render reach pages behind authentication. Headers and
+// basic auth are only sent to the origin of the URL being rendered, never
+// to third parties or cross-origin redirects. Cookies are set on the tab
+// before navigation and cleared when the tab goes back to the pool.
+type Credentials struct {
+	Headers   map[string]string
+	Cookies   []Cookie
+	BasicAuth *BasicAuth
+}
+
+// String keeps secrets out of logs and error messages.
+func (c *Credentials) String() string {
+	return "[redacted]"
+}
+
+func (c *Credentials) GoString() string {
+	return c.String()
+}
+
+func (c *Credentials) Validate() error {
+	for name := range c.Headers {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: header name cannot be empty", ErrInvalidCredentials)
+		}
+	}
+	for i, cookie := range c.Cookies {
+		if cookie.Name == "" {
+			return fmt.Errorf("%w: cookies[%d] has no name", ErrInvalidCredentials, i)
+		}
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Username == "" {
+		return fmt.Errorf("%w: basic auth requires a username", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// apply sets cookies on the tab. targetURL scopes cookies that have no
+// explicit domain.
+func (c *Credentials) apply(targetURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, cookie := range c.Cookies {
+			params := network.SetCookie(cookie.Name, cookie.Value)
+			if cookie.Domain != "" {
+				params = params.WithDomain(cookie.Domain)
+			} else if targetURL != "" {
+				params = params.WithURL(targetURL)
+			} else {
+				return fmt.Errorf("%w: cookie %q needs a domain", ErrInvalidCredentials, cookie.Name)
+			}
+			if cookie.Path != "" {
+				params = params.WithPath(cookie.Path)
+			}
+			if err := params.Do(ctx); err != nil {
+				return fmt.Errorf("failed to set cookie %q: %w", cookie.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// origin returns scheme://host[:port] the way Chrome reports it in auth
+// challenges, or "" if rawURL is not absolute.
+func origin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	return scheme + "://" + host
+}
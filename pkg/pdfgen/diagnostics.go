@@ -0,0 +1,201 @@
+package pdfgen
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// maxDiagnosticEntries caps each list so a page that fires thousands of
+// requests cannot blow up the job record.
+const maxDiagnosticEntries = 500
+
+const maxDiagnosticTextLen = 2000
+
+// BlockedRequest is a request the render's network policy or URL guard
+// refused.
+type BlockedRequest struct {
+	URL          string
+	ResourceType string
+	Reason       string
+}
+
+// ConsoleMessage is a console call made by the page, or a message Chrome
+// logged on its behalf (source "browser").
+type ConsoleMessage struct {
+	Level  string
+	Text   string
+	Source string
+	URL    string
+	Line   int64
+}
+
+// PageException is an uncaught JavaScript exception.
+type PageException struct {
+	Message string
+	URL     string
+	Line    int64
+	Column  int64
+}
+
+// FailedRequest is a load that failed outright (Error set) or got a 4xx or
+// 5xx response (Status set).
+type FailedRequest struct {
+	URL          string
+	ResourceType string
+	Status       int64
+	Error        string
+}
+
+// Diagnostics is what a render observed besides its output. It is returned
+// even when the render fails, to help explain why.
+type Diagnostics struct {
+	ConsoleMessages []ConsoleMessage
+	Exceptions      []PageException
+	FailedRequests  []FailedRequest
+	BlockedRequests []BlockedRequest
+}
+
+type diagnosticsRecorder struct {
+	mu       sync.Mutex
+	d        Diagnostics
+	requests map[network.RequestID]*network.Request
+}
+
+func appendCapped[T any](list []T, item T) []T {
+	if len(list) >= maxDiagnosticEntries {
+		return list
+	}
+	return append(list, item)
+}
+
+func truncateText(s string) string {
+	if len(s) <= maxDiagnosticTextLen {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxDiagnosticTextLen], "") + "…"
+}
+
+func (r *diagnosticsRecorder) blocked(url, resourceType, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.d.BlockedRequests = appendCapped(r.d.BlockedRequests, BlockedRequest{URL: url, ResourceType: resourceType, Reason: reason})
+}
+
+// listen starts collecting console output, exceptions and failed loads.
+// It must run before the document loads.
+func (r *diagnosticsRecorder) listen() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		r.requests = make(map[network.RequestID]*network.Request)
+		chromedp.ListenTarget(ctx, r.handle)
+		return nil
+	})
+}
+
+func (r *diagnosticsRecorder) handle(ev any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		msg := ConsoleMessage{Level: string(ev.Type), Text: truncateText(consoleText(ev.Args)), Source: "console"}
+		if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+			msg.URL = ev.StackTrace.CallFrames[0].URL
+			msg.Line = ev.StackTrace.CallFrames[0].LineNumber + 1
+		}
+		r.d.ConsoleMessages = appendCapped(r.d.ConsoleMessages, msg)
+
+	case *log.EventEntryAdded:
+		// Network failures are reported separately below.
+		if ev.Entry.Source == log.SourceNetwork {
+			return
+		}
+		msg := ConsoleMessage{Level: string(ev.Entry.Level), Text: truncateText(ev.Entry.Text), Source: "browser"}
+		if ev.Entry.URL != "" {
+			msg.URL = ev.Entry.URL
+			msg.Line = ev.Entry.LineNumber + 1
+		}
+		r.d.ConsoleMessages = appendCapped(r.d.ConsoleMessages, msg)
+
+	case *runtime.EventExceptionThrown:
+		d := ev.ExceptionDetails
+		message := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			message = d.Exception.Description
+		}
+		r.d.Exceptions = appendCapped(r.d.Exceptions, PageException{
+			Message: truncateText(message),
+			URL:     d.URL,
+			Line:    d.LineNumber + 1,
+			Column:  d.ColumnNumber + 1,
+		})
+
+	case *network.EventRequestWillBeSent:
+		r.requests[ev.RequestID] = ev.Request
+
+	case *network.EventResponseReceived:
+		if ev.Response.Status >= 400 {
+			r.d.FailedRequests = appendCapped(r.d.FailedRequests, FailedRequest{
+				URL:          ev.Response.URL,
+				ResourceType: strings.ToLower(string(ev.Type)),
+				Status:       ev.Response.Status,
+			})
+		}
+
+	case *network.EventLoadingFailed:
+		req, ok := r.requests[ev.RequestID]
+		delete(r.requests, ev.RequestID)
+		if !ok || ev.Canceled {
+			return
+		}
+		r.d.FailedRequests = appendCapped(r.d.FailedRequests, FailedRequest{
+			URL:          req.URL,
+			ResourceType: strings.ToLower(string(ev.Type)),
+			Error:        ev.ErrorText,
+		})
+
+	case *network.EventLoadingFinished:
+		delete(r.requests, ev.RequestID)
+	}
+}
+
+func consoleText(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case len(arg.Value) > 0:
+			var s string
+			if json.Unmarshal(arg.Value, &s) == nil {
+				parts = append(parts, s)
+			} else {
+				parts = append(parts, string(arg.Value))
+			}
+		case arg.UnserializableValue != "":
+			parts = append(parts, string(arg.UnserializableValue))
+		case arg.Description != "":
+			parts = append(parts, arg.Description)
+		default:
+			parts = append(parts, string(arg.Type))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *diagnosticsRecorder) snapshot() *Diagnostics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Diagnostics{
+		ConsoleMessages: append([]ConsoleMessage(nil), r.d.ConsoleMessages...),
+		Exceptions:      append([]PageException(nil), r.d.Exceptions...),
+		FailedRequests:  append([]FailedRequest(nil), r.d.FailedRequests...),
+		BlockedRequests: append([]BlockedRequest(nil), r.d.BlockedRequests...),
+	}
+}
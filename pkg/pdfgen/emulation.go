@@ -0,0 +1,89 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidEmulation = errors.New("invalid emulation option")
+
+// MediaType selects which CSS media the page is printed with. Chrome uses
+// print by default; screen keeps the on-screen look.
+type MediaType string
+
+const (
+	MediaPrint  MediaType = "print"
+	MediaScreen MediaType = "screen"
+)
+
+type ColorScheme string
+
+const (
+	ColorSchemeLight ColorScheme = "light"
+	ColorSchemeDark  ColorScheme = "dark"
+)
+
+type ReducedMotion string
+
+const (
+	ReducedMotionReduce       ReducedMotion = "reduce"
+	ReducedMotionNoPreference ReducedMotion = "no-preference"
+)
+
+// Emulation overrides media queries for a render. Empty fields leave
+// Chrome's defaults alone.
+type Emulation struct {
+	MediaType     MediaType
+	ColorScheme   ColorScheme
+	ReducedMotion ReducedMotion
+}
+
+func (e Emulation) IsZero() bool {
+	return e == Emulation{}
+}
+
+func (e Emulation) Validate() error {
+	switch e.MediaType {
+	case "", MediaPrint, MediaScreen:
+	default:
+		return fmt.Errorf("%w: media type %q", ErrInvalidEmulation, e.MediaType)
+	}
+	switch e.ColorScheme {
+	case "", ColorSchemeLight, ColorSchemeDark:
+	default:
+		return fmt.Errorf("%w: color scheme %q", ErrInvalidEmulation, e.ColorScheme)
+	}
+	switch e.ReducedMotion {
+	case "", ReducedMotionReduce, ReducedMotionNoPreference:
+	default:
+		return fmt.Errorf("%w: reduced motion %q", ErrInvalidEmulation, e.ReducedMotion)
+	}
+	return nil
+}
+
+// apply must run before the document loads so scripts reading
+// matchMedia see the emulated values.
+func (e Emulation) apply() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if e.IsZero() {
+			return nil
+		}
+
+		var features []*emulation.MediaFeature
+		if e.ColorScheme != "" {
+			features = append(features, &emulation.MediaFeature{Name: "prefers-color-scheme", Value: string(e.ColorScheme)})
+		}
+		if e.ReducedMotion != "" {
+			features = append(features, &emulation.MediaFeature{Name: "prefers-reduced-motion", Value: string(e.ReducedMotion)})
+		}
+
+		return emulation.SetEmulatedMedia().
+			WithMedia(string(e.MediaType)).
+			WithFeatures(features).
+			Do(ctx)
+	})
+}
@@ -0,0 +1,172 @@
+package pdfgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var ErrInvalidEncryption = errors.New("invalid encryption")
+
+// Permissions are what a reader who opened the document with the user
+// password may do. The owner password grants everything.
+type Permissions uint8
+
+const (
+	PermitPrint Permissions = 1 << iota
+	PermitCopy
+	PermitModify
+	PermitAnnotate
+
+	PermitNone Permissions = 0
+	PermitAll              = PermitPrint | PermitCopy | PermitModify | PermitAnnotate
+)
+
+var permissionNames = map[string]Permissions{
+	"print":    PermitPrint,
+	"copy":     PermitCopy,
+	"modify":   PermitModify,
+	"annotate": PermitAnnotate,
+}
+
+// ParsePermissions maps names such as "print" and "copy" to Permissions.
+func ParsePermissions(names []string) (Permissions, error) {
+	var p Permissions
+	for _, name := range names {
+		flag, ok := permissionNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("%w: unknown permission %q, expected print, copy, modify or annotate", ErrInvalidEncryption, name)
+		}
+		p |= flag
+	}
+	return p, nil
+}
+
+// flags maps p onto the PDF permission bits, including their revision 3
+// counterparts.
+func (p Permissions) flags() model.PermissionFlags {
+	flags := model.PermissionsNone
+	if p&PermitPrint != 0 {
+		flags |= model.PermissionPrintRev2 | model.PermissionPrintRev3
+	}
+	if p&PermitCopy != 0 {
+		flags |= model.PermissionExtract | model.PermissionExtractRev3
+	}
+	if p&PermitModify != 0 {
+		flags |= model.PermissionModify | model.PermissionAssembleRev3
+	}
+	if p&PermitAnnotate != 0 {
+		flags |= model.PermissionModAnnFillForm | model.PermissionFillRev3
+	}
+	return flags
+}
+
+// Encryption protects PDF output with AES-256. An empty UserPassword lets
+// anyone open the document, with only Permissions allowed.
+type Encryption struct {
+	UserPassword  string
+	OwnerPassword string
+	Permissions   Permissions
+}
+
+// maxPasswordLength is the longest password AES-256 PDF encryption uses,
+// in bytes of UTF-8.
+const maxPasswordLength = 127
+
+func (e *Encryption) Validate() error {
+	if e.OwnerPassword == "" {
+		return fmt.Errorf("%w: owner password is required", ErrInvalidEncryption)
+	}
+	if e.UserPassword == e.OwnerPassword {
+		return fmt.Errorf("%w: user and owner passwords must differ, or permissions would not apply", ErrInvalidEncryption)
+	}
+	if len(e.UserPassword) > maxPasswordLength || len(e.OwnerPassword) > maxPasswordLength {
+		return fmt.Errorf("%w: passwords must be at most %d bytes", ErrInvalidEncryption, maxPasswordLength)
+	}
+	return nil
+}
+
+// String leaves the passwords out, so an Encryption can be logged.
+func (e Encryption) String() string {
+	return fmt.Sprintf("AES-256 (user password: %t, permissions: %s)", e.UserPassword != "", e.Permissions)
+}
+
+func (p Permissions) String() string {
+	var names []string
+	for _, name := range []string{"print", "copy", "modify", "annotate"} {
+		if p&permissionNames[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// encryptPDF returns pdf encrypted with AES-256.
+func encryptPDF(pdf []byte, e *Encryption) ([]byte, error) {
+	in, err := api.ReadContext(bytes.NewReader(pdf), pdfConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	var producer string
+	if in.Info != nil {
+		if info, err := in.DereferenceDict(*in.Info); err == nil && info != nil {
+			if v, ok := info["Producer"]; ok {
+				producer, _ = in.DereferenceStringOrHexLiteral(v, model.V10, nil)
+			}
+		}
+	}
+
+	conf := pdfConfig()
+	conf.EncryptUsingAES = true
+	conf.EncryptKeyLength = 256
+	conf.UserPW = e.UserPassword
+	conf.OwnerPW = e.OwnerPassword
+	conf.Permissions = e.Permissions.flags()
+
+	var buf bytes.Buffer
+	if err := api.Encrypt(bytes.NewReader(pdf), &buf, conf); err != nil {
+		return nil, fmt.Errorf("failed to encrypt PDF: %w", err)
+	}
+	return restoreProducer(buf.Bytes(), producer, e.OwnerPassword)
+}
+
+// restoreProducer puts back the Producer pdfcpu replaces with its own when
+// it rewrites a document, as an encrypted incremental update.
+func restoreProducer(pdf []byte, producer, ownerPassword string) ([]byte, error) {
+	if producer == "" {
+		return pdf, nil
+	}
+
+	conf := pdfConfig()
+	conf.OwnerPW = ownerPassword
+	ctx, err := api.ReadContext(bytes.NewReader(pdf), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt PDF: %w", err)
+	}
+	if ctx.Info == nil {
+		return pdf, nil
+	}
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt PDF: %w", err)
+	}
+	if info == nil {
+		return pdf, nil
+	}
+	s, err := types.EscapedUTF16String(producer)
+	if err != nil {
+		return nil, err
+	}
+	info["Producer"] = types.StringLiteral(*s)
+	ctx.Write.IncrementWithObjNr(ctx.Info.ObjectNumber.Value())
+
+	return appendIncrement(ctx, pdf)
+}
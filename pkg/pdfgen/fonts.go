@@ -0,0 +1,558 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	ErrInvalidFont          = errors.New("invalid font")
+	ErrInvalidGoogleFontURL = errors.New("not a Google Fonts stylesheet URL")
+)
+
+// fontOrigin is where registered fonts are served from during a render.
+const fontOrigin = "http://fonts.pdfgen.invalid"
+
+const (
+	googleFontsCSSHost  = "fonts.googleapis.com"
+	googleFontsFileHost = "fonts.gstatic.com"
+
+	// Google picks the font format from the User-Agent; anything this
+	// recent gets woff2, which is what Chrome will ask for during renders.
+	googleFontsUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	maxGoogleFontSize      = 10 << 20
+	maxGoogleFontCacheSize = 1 << 30
+)
+
+type FontStyle string
+
+const (
+	FontStyleNormal FontStyle = "normal"
+	FontStyleItalic FontStyle = "italic"
+)
+
+// Font is one registered face. File is the name it is stored and served
+// under, derived from its content.
+type Font struct {
+	Family string    `json:"family"`
+	Weight int       `json:"weight"`
+	Style  FontStyle `json:"style"`
+	Format string    `json:"format"`
+	File   string    `json:"file"`
+	Size   int64     `json:"size"`
+}
+
+// FontLibrary is a directory of fonts that every render can use without
+// network access. Registered families get @font-face rules injected into
+// the page, and Google Fonts stylesheets and files are answered from a
+// local mirror.
+type FontLibrary struct {
+	dir     string
+	offline bool
+	client  *http.Client
+
+	mu    sync.RWMutex
+	fonts []Font
+
+	cacheMu   sync.Mutex
+	cacheSize int64 // bytes in the Google Fonts mirror
+}
+
+// OpenFontLibrary loads the library in dir, creating it if needed. With
+// offline set, Google Fonts requests missing from the mirror fail instead
+// of being fetched.
+func OpenFontLibrary(dir string, offline bool) (*FontLibrary, error) {
+	for _, d := range []string{dir, filepath.Join(dir, "files"), filepath.Join(dir, "google")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create font directory: %w", err)
+		}
+	}
+
+	l := &FontLibrary{
+		dir:     dir,
+		offline: offline,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	data, err := os.ReadFile(l.manifestPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read font manifest: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &l.fonts); err != nil {
+			return nil, fmt.Errorf("failed to parse font manifest: %w", err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "google"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Google Fonts mirror: %w", err)
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			l.cacheSize += info.Size()
+		}
+	}
+	return l, nil
+}
+
+func (l *FontLibrary) manifestPath() string {
+	return filepath.Join(l.dir, "fonts.json")
+}
+
+func (l *FontLibrary) hasFonts() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.fonts) > 0
+}
+
+// Fonts returns the registered faces sorted by family, weight and style.
+func (l *FontLibrary) Fonts() []Font {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	fonts := append([]Font(nil), l.fonts...)
+	sort.Slice(fonts, func(i, j int) bool {
+		a, b := fonts[i], fonts[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		return a.Style < b.Style
+	})
+	return fonts
+}
+
+// Add registers data as a face of family, replacing any face with the same
+// weight and style. The format is detected from the file itself.
+func (l *FontLibrary) Add(family string, weight int, style FontStyle, data []byte) (Font, error) {
+	family = strings.TrimSpace(family)
+	if family == "" || len(family) > 100 || strings.ContainsAny(family, "\"'\\<>;{}\n\r") {
+		return Font{}, fmt.Errorf("%w: family name %q", ErrInvalidFont, family)
+	}
+	if weight == 0 {
+		weight = 400
+	}
+	if weight < 1 || weight > 1000 {
+		return Font{}, fmt.Errorf("%w: weight must be between 1 and 1000", ErrInvalidFont)
+	}
+	if style == "" {
+		style = FontStyleNormal
+	}
+	if style != FontStyleNormal && style != FontStyleItalic {
+		return Font{}, fmt.Errorf("%w: style %q", ErrInvalidFont, style)
+	}
+	format, ext := sniffFontFormat(data)
+	if format == "" {
+		return Font{}, fmt.Errorf("%w: not a ttf, otf, woff or woff2 file", ErrInvalidFont)
+	}
+
+	sum := sha256.Sum256(data)
+	font := Font{
+		Family: family,
+		Weight: weight,
+		Style:  style,
+		Format: format,
+		File:   hex.EncodeToString(sum[:12]) + ext,
+		Size:   int64(len(data)),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := writeFileAtomic(filepath.Join(l.dir, "files", font.File), data); err != nil {
+		return Font{}, fmt.Errorf("failed to store font: %w", err)
+	}
+
+	var replaced string
+	fonts := make([]Font, 0, len(l.fonts)+1)
+	for _, f := range l.fonts {
+		if strings.EqualFold(f.Family, family) && f.Weight == weight && f.Style == style {
+			replaced = f.File
+			continue
+		}
+		fonts = append(fonts, f)
+	}
+	fonts = append(fonts, font)
+
+	manifest, err := json.MarshalIndent(fonts, "", "  ")
+	if err != nil {
+		return Font{}, err
+	}
+	if err := writeFileAtomic(l.manifestPath(), manifest); err != nil {
+		return Font{}, fmt.Errorf("failed to update font manifest: %w", err)
+	}
+	l.fonts = fonts
+
+	if replaced != "" && !fontFileInUse(fonts, replaced) {
+		os.Remove(filepath.Join(l.dir, "files", replaced))
+	}
+	return font, nil
+}
+
+func fontFileInUse(fonts []Font, file string) bool {
+	for _, f := range fonts {
+		if f.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+func sniffFontFormat(data []byte) (format, ext string) {
+	if len(data) < 4 {
+		return "", ""
+	}
+	switch magic := string(data[:4]); {
+	case magic == "wOF2":
+		return "woff2", ".woff2"
+	case magic == "wOFF":
+		return "woff", ".woff"
+	case magic == "OTTO":
+		return "opentype", ".otf"
+	case magic == "\x00\x01\x00\x00" || magic == "true":
+		return "truetype", ".ttf"
+	}
+	return "", ""
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *FontLibrary) fontFaceCSS() string {
+	var b strings.Builder
+	for _, f := range l.Fonts() {
+		fmt.Fprintf(&b, "@font-face{font-family:\"%s\";font-weight:%d;font-style:%s;font-display:block;src:url(%s/%s) format(\"%s\");}\n",
+			f.Family, f.Weight, f.Style, fontOrigin, f.File, f.Format)
+	}
+	return b.String()
+}
+
+// inject adds the library's @font-face rules to the loaded page and waits
+// for the faces it uses to arrive.
+func (l *FontLibrary) inject() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		css := l.fontFaceCSS()
+		if css == "" {
+			return nil
+		}
+		quoted, err := json.Marshal(css)
+		if err != nil {
+			return err
+		}
+		script := fmt.Sprintf(`(async () => {
+			const style = document.createElement("style");
+			style.dataset.pdfgen = "fonts";
+			style.textContent = %s;
+			(document.head || document.documentElement).appendChild(style);
+			void document.documentElement.offsetHeight;
+			await document.fonts.ready;
+		})()`, quoted)
+		return chromedp.Evaluate(script, nil,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+				return p.WithAwaitPromise(true)
+			},
+		).Do(ctx)
+	})
+}
+
+func (l *FontLibrary) serveFonts(ev *fetch.EventRequestPaused) *requestDecision {
+	if origin(ev.Request.URL) != fontOrigin {
+		return nil
+	}
+	u, err := url.Parse(ev.Request.URL)
+	if err != nil {
+		return &requestDecision{Status: http.StatusNotFound}
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return &requestDecision{Status: http.StatusNotFound}
+	}
+	body, err := os.ReadFile(filepath.Join(l.dir, "files", name))
+	if err != nil {
+		return &requestDecision{Status: http.StatusNotFound}
+	}
+	return &requestDecision{
+		Status: http.StatusOK,
+		Headers: []*fetch.HeaderEntry{
+			{Name: "Content-Type", Value: assetContentType(name)},
+			{Name: "Access-Control-Allow-Origin", Value: "*"},
+		},
+		Body: body,
+	}
+}
+
+// referencesGoogleFonts reports whether html may load anything from
+// Google Fonts, so the mirror only intercepts renders that need it.
+func referencesGoogleFonts(html string) bool {
+	return strings.Contains(html, googleFontsCSSHost) || strings.Contains(html, googleFontsFileHost)
+}
+
+// serveMirroredGoogleFonts answers Google Fonts requests that are already
+// in the mirror and lets the rest through.
+func (l *FontLibrary) serveMirroredGoogleFonts(ev *fetch.EventRequestPaused) *requestDecision {
+	u, ok := googleFontURL(ev.Request.URL)
+	if !ok {
+		return nil
+	}
+	contentType, body, ok := l.mirroredGoogleFont(u)
+	if !ok {
+		return nil
+	}
+	return googleFontResponse(contentType, body)
+}
+
+// fetchGoogleFonts answers the remaining Google Fonts requests by fetching
+// them into the mirror, or fails them when the library is offline.
+func (l *FontLibrary) fetchGoogleFonts(ctx context.Context) requestRule {
+	return func(ev *fetch.EventRequestPaused) *requestDecision {
+		u, ok := googleFontURL(ev.Request.URL)
+		if !ok {
+			return nil
+		}
+		if l.offline {
+			return &requestDecision{FailReason: network.ErrorReasonInternetDisconnected}
+		}
+		contentType, body, err := l.googleFont(ctx, u, headerValue(ev.Request.Headers, "User-Agent"))
+		if err != nil && body == nil {
+			return &requestDecision{FailReason: network.ErrorReasonFailed}
+		}
+		return googleFontResponse(contentType, body)
+	}
+}
+
+func googleFontResponse(contentType string, body []byte) *requestDecision {
+	return &requestDecision{
+		Status: http.StatusOK,
+		Headers: []*fetch.HeaderEntry{
+			{Name: "Content-Type", Value: contentType},
+			{Name: "Access-Control-Allow-Origin", Value: "*"},
+		},
+		Body: body,
+	}
+}
+
+// googleFontURL returns rawURL in the form the mirror stores it under:
+// stylesheets keep only the parameters that shape the CSS, with families
+// sorted, and font files lose their query. ok is false for anything that
+// isn't a Google Fonts stylesheet or file.
+func googleFontURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return "", false
+	}
+	switch u.Host {
+	case googleFontsCSSHost:
+		if u.Path != "/css" && u.Path != "/css2" && u.Path != "/icon" {
+			return "", false
+		}
+		// url.Query drops pairs containing ';', which css2 axis lists use.
+		var families, params []string
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			value, err := url.QueryUnescape(value)
+			if err != nil || value == "" {
+				continue
+			}
+			switch key {
+			case "family":
+				if u.Path != "/css2" {
+					families = append(families, strings.Split(value, "|")...)
+				} else {
+					families = append(families, value)
+				}
+			case "display", "subset", "text":
+				params = append(params, key+"="+googleFontsQueryEscaper.Replace(value))
+			}
+		}
+		if len(families) == 0 {
+			return "", false
+		}
+		sort.Strings(families)
+		if u.Path != "/css2" {
+			families = []string{strings.Join(families, "|")}
+		}
+		for i, f := range families {
+			families[i] = "family=" + googleFontsQueryEscaper.Replace(f)
+		}
+		sort.Strings(params)
+		query := strings.Join(append(families, params...), "&")
+		return "https://" + googleFontsCSSHost + u.Path + "?" + query, true
+	case googleFontsFileHost:
+		if u.Path == "" || u.Path == "/" {
+			return "", false
+		}
+		return "https://" + googleFontsFileHost + u.EscapedPath(), true
+	}
+	return "", false
+}
+
+// googleFontsQueryEscaper escapes only what would change the meaning of a
+// query, keeping family specs readable.
+var googleFontsQueryEscaper = strings.NewReplacer("%", "%25", "&", "%26", "+", "%2B", "#", "%23", "=", "%3D", " ", "+")
+
+func headerValue(headers network.Headers, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			if s, ok := v.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func (l *FontLibrary) mirroredGoogleFont(fontURL string) (string, []byte, bool) {
+	base := l.googleCachePath(fontURL)
+	body, err := os.ReadFile(base)
+	if err != nil {
+		return "", nil, false
+	}
+	contentType, err := os.ReadFile(base + ".type")
+	if err != nil {
+		return "", nil, false
+	}
+	return string(contentType), body, true
+}
+
+// googleFont returns fontURL, a URL from googleFontURL, from the mirror,
+// fetching and storing it first when missing. Once the mirror is full a
+// fetched file is still returned, along with the error saying it wasn't
+// kept.
+func (l *FontLibrary) googleFont(ctx context.Context, fontURL, userAgent string) (string, []byte, error) {
+	if contentType, body, ok := l.mirroredGoogleFont(fontURL); ok {
+		return contentType, body, nil
+	}
+	if l.offline {
+		return "", nil, fmt.Errorf("%s is not mirrored", fontURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fontURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if userAgent == "" {
+		userAgent = googleFontsUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("fetching %s: %s", fontURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGoogleFontSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(body) > maxGoogleFontSize {
+		return "", nil, fmt.Errorf("%s is larger than %d bytes", fontURL, maxGoogleFontSize)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = assetContentType(fontURL)
+	}
+
+	if err := l.storeGoogleFont(fontURL, contentType, body); err != nil {
+		return contentType, body, err
+	}
+	return contentType, body, nil
+}
+
+func (l *FontLibrary) storeGoogleFont(fontURL, contentType string, body []byte) error {
+	size := int64(len(body) + len(contentType))
+
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+	if l.cacheSize+size > maxGoogleFontCacheSize {
+		return fmt.Errorf("the Google Fonts mirror is full (%d bytes)", int64(maxGoogleFontCacheSize))
+	}
+
+	base := l.googleCachePath(fontURL)
+	if err := writeFileAtomic(base+".type", []byte(contentType)); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(base, body); err != nil {
+		return err
+	}
+	l.cacheSize += size
+	return nil
+}
+
+func (l *FontLibrary) googleCachePath(fontURL string) string {
+	sum := sha256.Sum256([]byte(fontURL))
+	return filepath.Join(l.dir, "google", hex.EncodeToString(sum[:]))
+}
+
+var cssURLPattern = regexp.MustCompile(`url\(\s*['"]?(https://fonts\.gstatic\.com/[^'")\s]+)['"]?\s*\)`)
+
+// MirrorGoogleFonts stores a Google Fonts stylesheet and every font file it
+// references, so later renders using it work offline. It returns the number
+// of font files in the stylesheet.
+func (l *FontLibrary) MirrorGoogleFonts(ctx context.Context, cssURL string) (int, error) {
+	u, ok := googleFontURL(cssURL)
+	if !ok || !strings.HasPrefix(u, "https://"+googleFontsCSSHost+"/") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidGoogleFontURL, cssURL)
+	}
+	if l.offline {
+		return 0, errors.New("font library is offline")
+	}
+
+	_, css, err := l.googleFont(ctx, u, googleFontsUserAgent)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range cssURLPattern.FindAllSubmatch(css, -1) {
+		fileURL, ok := googleFontURL(string(bytes.TrimSpace(m[1])))
+		if !ok || seen[fileURL] {
+			continue
+		}
+		seen[fileURL] = true
+		if _, _, err := l.googleFont(ctx, fileURL, googleFontsUserAgent); err != nil {
+			return len(seen), err
+		}
+	}
+	return len(seen), nil
+}
@@ -1,6 +1,7 @@
 package pdfgen
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,9 +9,6 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/chromedp"
 )
 
 var (
@@ -18,18 +16,109 @@ var (
 	ErrInvalidOutputPath = errors.New("invalid output path")
 )
 
+type Config struct {
+	Timeout time.Duration
+	Pool    PoolConfig
+	Chrome  ChromeConfig
+
+	// AssetRoot is served to raw HTML renders that don't set their own
+	// AssetDir, so relative images, fonts and stylesheets resolve.
+	AssetRoot string
+
+	// Fonts, when set, is available to every render: its families are
+	// injected as @font-face rules and Google Fonts come from its mirror.
+	Fonts *FontLibrary
+
+	// URLGuard, when set, vets the URL of every render and every request
+	// the page makes. Launched browsers send all their traffic through a
+	// proxy that enforces it; with a remote browser only the requests of
+	// the render's own tab are checked.
+	URLGuard *URLGuard
+
+	// Signer, when set, signs PDF output that asks for a Signature.
+	Signer *Signer
+}
+
 type Generator struct {
-	timeout time.Duration
+	timeout   time.Duration
+	pool      *browserPool
+	assetRoot string
+	fonts     *FontLibrary
+	urlGuard  *URLGuard
+	signer    *Signer
 }
 
 func NewGenerator(timeout time.Duration) *Generator {
-	if timeout == 0 {
-		timeout = 30 * time.Second
+	return NewGeneratorWithConfig(Config{Timeout: timeout, Pool: DefaultPoolConfig()})
+}
+
+func NewGeneratorWithConfig(cfg Config) *Generator {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	return &Generator{
+		timeout:   cfg.Timeout,
+		pool:      newBrowserPool(cfg.Pool, cfg.Chrome, cfg.URLGuard),
+		assetRoot: cfg.AssetRoot,
+		fonts:     cfg.Fonts,
+		urlGuard:  cfg.URLGuard,
+		signer:    cfg.Signer,
 	}
-	return &Generator{timeout: timeout}
 }
 
-func (g *Generator) Close() {}
+// CheckURL reports whether the Generator's URLGuard allows rawURL. It is
+// always nil without a guard.
+func (g *Generator) CheckURL(ctx context.Context, rawURL string) error {
+	if g.urlGuard == nil {
+		return nil
+	}
+	return g.urlGuard.Check(ctx, rawURL)
+}
+
+// CanSign reports whether the Generator has a Signer for renders that ask
+// for a Signature.
+func (g *Generator) CanSign() bool {
+	return g.signer != nil
+}
+
+// Close shuts down every browser in the pool. Renders still in flight fail
+// and later calls return ErrGeneratorClosed.
+func (g *Generator) Close() {
+	g.pool.close()
+}
+
+// withTab checks a tab out of the pool for the duration of fn. If ctx has
+// no deadline of its own the generator timeout applies, covering both the
+// wait for a free tab and the work done in it. Cancelling ctx aborts the
+// Chrome work in progress and the tab is closed rather than reused.
+func (g *Generator) withTab(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	t, err := g.pool.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire browser tab: %w", err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	tabCtx, cancel := context.WithDeadline(t.ctx, deadline)
+	stop := context.AfterFunc(ctx, cancel)
+
+	err = fn(tabCtx)
+
+	stop()
+	cancel()
+	g.pool.release(t, ctx.Err() == nil)
+
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
 
 func (g *Generator) validateHTML(html string) error {
 	if strings.TrimSpace(html) == "" {
@@ -38,7 +127,7 @@ func (g *Generator) validateHTML(html string) error {
 	return nil
 }
 
-func (g *Generator) validateOutputPath(outputPath string) error {
+func (g *Generator) validateOutputPath(outputPath string, format OutputFormat) error {
 	if strings.TrimSpace(outputPath) == "" {
 		return ErrInvalidOutputPath
 	}
@@ -50,155 +139,92 @@ func (g *Generator) validateOutputPath(outputPath string) error {
 		}
 	}
 
-	if !strings.HasSuffix(strings.ToLower(outputPath), ".pdf") {
-		return fmt.Errorf("%w: file must have .pdf extension", ErrInvalidOutputPath)
+	ext := strings.ToLower(filepath.Ext(outputPath))
+	if ext != format.Extension() && !(format == OutputJPEG && ext == ".jpeg") {
+		return fmt.Errorf("%w: file must have %s extension", ErrInvalidOutputPath, format.Extension())
 	}
 
 	return nil
 }
 
-func (g *Generator) FromHTML(html string, outputPath string) error {
-	return g.FromHTMLWithCustomOptions(html, outputPath, DefaultPrintOptions())
-}
-
-func (g *Generator) FromHTMLWithCustomOptions(html string, outputPath string, opts *PrintOptions) error {
-	if err := g.validateHTML(html); err != nil {
-		return err
-	}
-	if err := g.validateOutputPath(outputPath); err != nil {
-		return err
-	}
-
+// RenderFile renders source and writes the output to outputPath, whose
+// extension must match the output format. Nothing is written if the render
+// fails.
+func (g *Generator) RenderFile(ctx context.Context, source Source, outputPath string, opts *PrintOptions) (*Result, error) {
 	if opts == nil {
 		opts = DefaultPrintOptions()
 	}
-
-	return g.generatePDF(html, outputPath, opts.ToCDPParams(), opts.WaitBeforePrint)
-}
-
-func (g *Generator) generatePDF(html string, outputPath string, opts *page.PrintToPDFParams, waitTime time.Duration) error {
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
-	defer cancel()
-
-	// Allocator options
-	options := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.DisableGPU,
-		chromedp.NoSandbox,
-		chromedp.Flag("disable-dev-shm-usage", true),
-	)
-
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, options...)
-	defer allocCancel()
-
-	taskCtx, taskCancel := chromedp.NewContext(allocCtx)
-	defer taskCancel()
-
-	if opts == nil {
-		opts = page.PrintToPDF().WithPrintBackground(true)
+	if err := g.validateOutputPath(outputPath, opts.OutputFormat()); err != nil {
+		return nil, err
 	}
 
-	if waitTime == 0 {
-		waitTime = 1 * time.Second
+	var buf bytes.Buffer
+	res, err := g.RenderToResult(ctx, &buf, source, opts)
+	if err != nil {
+		return res, err
 	}
 
-	var pdfData []byte
-
-	if err := chromedp.Run(taskCtx,
-		chromedp.Navigate("about:blank"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			frameTree, err := page.GetFrameTree().Do(ctx)
-			if err != nil {
-				return err
-			}
-			return page.SetDocumentContent(frameTree.Frame.ID, html).Do(ctx)
-		}),
-		chromedp.Sleep(waitTime),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			pdfData, _, err = opts.Do(ctx)
-			return err
-		}),
-	); err != nil {
-		return fmt.Errorf("failed to generate PDF: %w", err)
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+		return res, err
 	}
+	return res, nil
+}
 
-	if len(pdfData) == 0 {
-		return errors.New("PDF generation resulted in empty file")
-	}
+func (g *Generator) writeFile(ctx context.Context, source Source, outputPath string, opts *PrintOptions) error {
+	_, err := g.RenderFile(ctx, source, outputPath, opts)
+	return err
+}
 
-	return os.WriteFile(outputPath, pdfData, 0644)
+func (g *Generator) FromHTML(html string, outputPath string) error {
+	return g.FromHTMLContext(context.Background(), html, outputPath)
 }
 
-func (g *Generator) FromURL(url string, outputPath string) error {
-	return g.FromURLWithCustomOptions(url, outputPath, DefaultPrintOptions())
+func (g *Generator) FromHTMLContext(ctx context.Context, html string, outputPath string) error {
+	return g.FromHTMLWithCustomOptionsContext(ctx, html, outputPath, DefaultPrintOptions())
 }
 
-func (g *Generator) FromURLWithCustomOptions(url string, outputPath string, opts *PrintOptions) error {
-	if strings.TrimSpace(url) == "" {
-		return errors.New("URL cannot be empty")
-	}
-	if err := g.validateOutputPath(outputPath); err != nil {
-		return err
-	}
+func (g *Generator) FromHTMLWithCustomOptions(html string, outputPath string, opts *PrintOptions) error {
+	return g.FromHTMLWithCustomOptionsContext(context.Background(), html, outputPath, opts)
+}
 
-	if opts == nil {
-		opts = DefaultPrintOptions()
+func (g *Generator) FromHTMLWithCustomOptionsContext(ctx context.Context, html string, outputPath string, opts *PrintOptions) error {
+	if err := g.validateHTML(html); err != nil {
+		return err
 	}
+	return g.writeFile(ctx, HTMLSource(html), outputPath, opts)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
-	defer cancel()
-
-	options := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.DisableGPU,
-		chromedp.NoSandbox,
-		chromedp.Flag("disable-dev-shm-usage", true),
-	)
-
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, options...)
-	defer allocCancel()
-
-	taskCtx, taskCancel := chromedp.NewContext(allocCtx)
-	defer taskCancel()
-
-	waitTime := opts.WaitBeforePrint
-	if waitTime == 0 {
-		waitTime = 2 * time.Second
-	}
+func (g *Generator) FromURL(url string, outputPath string) error {
+	return g.FromURLContext(context.Background(), url, outputPath)
+}
 
-	var pdfData []byte
+func (g *Generator) FromURLContext(ctx context.Context, url string, outputPath string) error {
+	return g.FromURLWithCustomOptionsContext(ctx, url, outputPath, DefaultPrintOptions())
+}
 
-	if err := chromedp.Run(taskCtx,
-		chromedp.Navigate(url),
-		chromedp.Sleep(waitTime),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			pdfData, _, err = opts.ToCDPParams().Do(ctx)
-			return err
-		}),
-	); err != nil {
-		return fmt.Errorf("failed to generate PDF from URL: %w", err)
-	}
+func (g *Generator) FromURLWithCustomOptions(url string, outputPath string, opts *PrintOptions) error {
+	return g.FromURLWithCustomOptionsContext(context.Background(), url, outputPath, opts)
+}
 
-	if len(pdfData) == 0 {
-		return errors.New("PDF generation resulted in empty file")
+func (g *Generator) FromURLWithCustomOptionsContext(ctx context.Context, url string, outputPath string, opts *PrintOptions) error {
+	if strings.TrimSpace(url) == "" {
+		return ErrInvalidURL
 	}
-
-	return os.WriteFile(outputPath, pdfData, 0644)
+	return g.writeFile(ctx, URLSource(url), outputPath, opts)
 }
 
 func (g *Generator) FromFile(htmlPath string, outputPath string) error {
-	return g.FromFileWithCustomOptions(htmlPath, outputPath, DefaultPrintOptions())
+	return g.FromFileContext(context.Background(), htmlPath, outputPath)
 }
 
-func (g *Generator) FromFileWithCustomOptions(htmlPath string, outputPath string, opts *PrintOptions) error {
-	htmlContent, err := os.ReadFile(htmlPath)
-	if err != nil {
-		return fmt.Errorf("failed to read HTML file %s: %w", htmlPath, err)
-	}
+func (g *Generator) FromFileContext(ctx context.Context, htmlPath string, outputPath string) error {
+	return g.FromFileWithCustomOptionsContext(ctx, htmlPath, outputPath, DefaultPrintOptions())
+}
 
-	if opts == nil {
-		opts = DefaultPrintOptions()
-	}
+func (g *Generator) FromFileWithCustomOptions(htmlPath string, outputPath string, opts *PrintOptions) error {
+	return g.FromFileWithCustomOptionsContext(context.Background(), htmlPath, outputPath, opts)
+}
 
-	return g.FromHTMLWithCustomOptions(string(htmlContent), outputPath, opts)
+func (g *Generator) FromFileWithCustomOptionsContext(ctx context.Context, htmlPath string, outputPath string, opts *PrintOptions) error {
+	return g.writeFile(ctx, FileSource(htmlPath), outputPath, opts)
 }
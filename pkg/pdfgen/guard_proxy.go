@@ -0,0 +1,224 @@
+package pdfgen
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const guardProxyDialTimeout = 30 * time.Second
+
+// guardProxy is the HTTP proxy launched browsers send all their traffic
+// through, so the URLGuard also covers what the Fetch domain never pauses:
+// WebSockets, workers and cross-site frames. It dials the addresses it
+// checked itself, which also closes the DNS rebinding window.
+type guardProxy struct {
+	guard    *URLGuard
+	upstream *url.URL
+	listener net.Listener
+	server   *http.Server
+	forward  *httputil.ReverseProxy
+}
+
+// startGuardProxy listens on a loopback port. With an upstream proxy,
+// hosts are still checked but connections go out through it.
+func startGuardProxy(guard *URLGuard, upstream string) (*guardProxy, error) {
+	p := &guardProxy{guard: guard}
+	if upstream != "" {
+		u, err := parseUpstreamProxy(upstream)
+		if err != nil {
+			return nil, err
+		}
+		p.upstream = u
+	}
+
+	transport := &http.Transport{
+		DialContext:           guard.dialContext,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if p.upstream != nil {
+		transport.DialContext = (&net.Dialer{Timeout: guardProxyDialTimeout}).DialContext
+		transport.Proxy = http.ProxyURL(p.upstream)
+	}
+	p.forward = &httputil.ReverseProxy{
+		// Requests arrive in absolute form, so the outgoing URL is already
+		// right; Rewrite only has to be there to drop X-Forwarded headers.
+		Rewrite:   func(r *httputil.ProxyRequest) {},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			proxyError(w, err)
+		},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start URL guard proxy: %w", err)
+	}
+	p.listener = ln
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go p.server.Serve(ln)
+	return p, nil
+}
+
+// parseUpstreamProxy accepts the plain HTTP proxies the guard can chain
+// to, written http://host:port or host:port.
+func parseUpstreamProxy(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "http" || u.Host == "" || strings.ContainsAny(raw, ";=") {
+		return nil, fmt.Errorf("%w: the URL guard can only chain to an http:// proxy, not %q", ErrInvalidChromeConfig, raw)
+	}
+	return u, nil
+}
+
+// url is what Chrome's --proxy-server is set to.
+func (p *guardProxy) url() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *guardProxy) close() {
+	p.server.Close()
+}
+
+func (p *guardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	if p.upstream != nil {
+		if err := p.guard.checkHost(r.Context(), r.URL.Hostname()); err != nil {
+			proxyError(w, err)
+			return
+		}
+	}
+	p.forward.ServeHTTP(w, r)
+}
+
+// tunnel serves CONNECT, which Chrome uses for https and for WebSockets.
+func (p *guardProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), guardProxyDialTimeout)
+	defer cancel()
+
+	var (
+		target net.Conn
+		err    error
+	)
+	if p.upstream != nil {
+		target, err = p.dialUpstream(ctx, r.Host)
+	} else {
+		target, err = p.guard.dialContext(ctx, "tcp", r.Host)
+	}
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+	defer target.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnelling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(target, buffered)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, target)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// dialUpstream checks hostPort and opens a tunnel to it through the
+// upstream proxy.
+func (p *guardProxy) dialUpstream(ctx context.Context, hostPort string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if err := p.guard.checkHost(ctx, host); err != nil {
+		return nil, err
+	}
+
+	addr := p.upstream.Host
+	if p.upstream.Port() == "" {
+		addr = net.JoinHostPort(addr, "80")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: hostPort},
+		Host:   hostPort,
+		Header: http.Header{},
+	}
+	if u := p.upstream.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy answered %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, errors.New("upstream proxy sent data before the tunnel was established")
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func proxyError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, ErrURLNotAllowed) {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,115 @@
+package pdfgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownSnippet = errors.New("unknown header/footer snippet")
+
+// Chrome fills elements carrying these classes in header and footer
+// templates. The {{name}} placeholders are shorthand for the matching span.
+const (
+	ClassPageNumber = "pageNumber"
+	ClassTotalPages = "totalPages"
+	ClassTitle      = "title"
+	ClassDate       = "date"
+	ClassURL        = "url"
+)
+
+// Chrome prints its own date/title header when DisplayHeaderFooter is set
+// and a template is empty, so unused slots get an empty element instead.
+const emptyTemplate = "<span></span>"
+
+const snippetStyle = `font-size:9px;width:100%;padding:0 0.4in;font-family:Helvetica,Arial,sans-serif;color:#555;`
+
+var placeholderReplacer = strings.NewReplacer(
+	"{{pageNumber}}", `<span class="pageNumber"></span>`,
+	"{{totalPages}}", `<span class="totalPages"></span>`,
+	"{{title}}", `<span class="title"></span>`,
+	"{{date}}", `<span class="date"></span>`,
+	"{{url}}", `<span class="url"></span>`,
+)
+
+var (
+	snippetsMu sync.RWMutex
+	snippets   = map[string]string{
+		"page-number": `<div style="` + snippetStyle + `text-align:center;">Page {{pageNumber}} of {{totalPages}}</div>`,
+		"title-date":  `<div style="` + snippetStyle + `display:flex;justify-content:space-between;"><span>{{title}}</span><span>{{date}}</span></div>`,
+		"url":         `<div style="` + snippetStyle + `text-align:left;">{{url}}</div>`,
+	}
+)
+
+// RegisterSnippet makes a reusable header/footer template available to
+// PrintOptions.HeaderSnippet and FooterSnippet. Registering an existing name
+// replaces it.
+func RegisterSnippet(name, html string) {
+	snippetsMu.Lock()
+	defer snippetsMu.Unlock()
+	snippets[name] = html
+}
+
+func Snippet(name string) (string, bool) {
+	snippetsMu.RLock()
+	defer snippetsMu.RUnlock()
+	html, ok := snippets[name]
+	return html, ok
+}
+
+func SnippetNames() []string {
+	snippetsMu.RLock()
+	defer snippetsMu.RUnlock()
+
+	names := make([]string, 0, len(snippets))
+	for name := range snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadSnippets registers every *.html file in dir as a snippet named after
+// the file without its extension.
+func LoadSnippets(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read snippet %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		RegisterSnippet(name, string(content))
+	}
+
+	return len(paths), nil
+}
+
+// ExpandTemplatePlaceholders turns {{pageNumber}}, {{totalPages}},
+// {{title}}, {{date}} and {{url}} into the spans Chrome fills in.
+func ExpandTemplatePlaceholders(html string) string {
+	return placeholderReplacer.Replace(html)
+}
+
+func resolveTemplate(inline, snippet string) (string, error) {
+	if inline != "" {
+		return ExpandTemplatePlaceholders(inline), nil
+	}
+	if snippet == "" {
+		return "", nil
+	}
+
+	html, ok := Snippet(snippet)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownSnippet, snippet)
+	}
+	return ExpandTemplatePlaceholders(html), nil
+}
@@ -0,0 +1,128 @@
+package pdfgen
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// requestDecision is what a rule wants done with a paused request. A zero
+// FailReason and Status means "not mine, ask the next rule".
+type requestDecision struct {
+	FailReason network.ErrorReason
+	Status     int64
+	Headers    []*fetch.HeaderEntry
+	Body       []byte
+}
+
+// requestRule inspects a paused request and returns nil to let it through.
+type requestRule func(ev *fetch.EventRequestPaused) *requestDecision
+
+// interceptor routes every request of a render through the Fetch domain so
+// rules can block, answer or pass it on, adds credential headers to
+// requests for the rendered origin, and answers HTTP auth challenges.
+// It is only switched on when a render actually needs it.
+type interceptor struct {
+	rules      []requestRule
+	basicAuth  *BasicAuth
+	headers    map[string]string
+	authOrigin string // the only origin basicAuth and headers are sent to
+}
+
+func (i *interceptor) addRule(rule requestRule) {
+	i.rules = append(i.rules, rule)
+}
+
+func (i *interceptor) enabled() bool {
+	return len(i.rules) > 0 || i.basicAuth != nil || len(i.headers) > 0
+}
+
+func (i *interceptor) start() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if !i.enabled() {
+			return nil
+		}
+
+		// Paused requests stay paused until answered, and answering from
+		// the listener itself would deadlock the event loop.
+		chromedp.ListenTarget(ctx, func(ev any) {
+			switch ev := ev.(type) {
+			case *fetch.EventRequestPaused:
+				go i.handleRequest(ctx, ev)
+			case *fetch.EventAuthRequired:
+				go i.handleAuth(ctx, ev)
+			}
+		})
+
+		return fetch.Enable().
+			WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}).
+			WithHandleAuthRequests(i.basicAuth != nil).
+			Do(ctx)
+	})
+}
+
+func (i *interceptor) handleRequest(ctx context.Context, ev *fetch.EventRequestPaused) {
+	for _, rule := range i.rules {
+		d := rule(ev)
+		if d == nil {
+			continue
+		}
+		switch {
+		case d.FailReason != "":
+			fetch.FailRequest(ev.RequestID, d.FailReason).Do(ctx)
+			return
+		case d.Status != 0:
+			fetch.FulfillRequest(ev.RequestID, d.Status).
+				WithResponseHeaders(d.Headers).
+				WithBody(base64.StdEncoding.EncodeToString(d.Body)).
+				Do(ctx)
+			return
+		}
+	}
+	continueRequest := fetch.ContinueRequest(ev.RequestID)
+	if len(i.headers) > 0 && i.authOrigin != "" && origin(ev.Request.URL) == i.authOrigin {
+		continueRequest = continueRequest.WithHeaders(withHeaders(ev.Request.Headers, i.headers))
+	}
+	continueRequest.Do(ctx)
+}
+
+// withHeaders returns the request's headers with extra set on top,
+// replacing any of the same name.
+func withHeaders(request network.Headers, extra map[string]string) []*fetch.HeaderEntry {
+	entries := make([]*fetch.HeaderEntry, 0, len(request)+len(extra))
+	for name, value := range request {
+		replaced := false
+		for extraName := range extra {
+			if strings.EqualFold(extraName, name) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			entries = append(entries, &fetch.HeaderEntry{Name: name, Value: fmt.Sprint(value)})
+		}
+	}
+	for name, value := range extra {
+		entries = append(entries, &fetch.HeaderEntry{Name: name, Value: value})
+	}
+	return entries
+}
+
+// handleAuth only ever hands credentials to the origin they were given for,
+// so a third-party frame or redirect cannot harvest them.
+func (i *interceptor) handleAuth(ctx context.Context, ev *fetch.EventAuthRequired) {
+	resp := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+	if i.basicAuth != nil && i.authOrigin != "" && ev.AuthChallenge.Origin == i.authOrigin {
+		resp = &fetch.AuthChallengeResponse{
+			Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+			Username: i.basicAuth.Username,
+			Password: i.basicAuth.Password,
+		}
+	}
+	fetch.ContinueWithAuth(ev.RequestID, resp).Do(ctx)
+}
@@ -0,0 +1,119 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var (
+	ErrInvalidPDF      = errors.New("invalid PDF")
+	ErrNothingToMerge  = errors.New("at least two PDFs are needed to merge")
+	ErrInvalidBookmark = errors.New("invalid bookmark")
+)
+
+func init() {
+	// pdfcpu otherwise reads and writes a config.yml in the user's config
+	// directory, and exits the process if it can't.
+	model.ConfigPath = "disable"
+}
+
+func pdfConfig() *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	// Watermarks are stamped into each page's content, so pages must not
+	// end up sharing a deduplicated content stream.
+	conf.OptimizeDuplicateContentStreams = false
+	return conf
+}
+
+// MergePart is one input of Merge. Name identifies it in errors.
+type MergePart struct {
+	Name string
+	PDF  io.ReadSeeker
+
+	// Bookmark, if set, adds a top-level bookmark pointing at the part's
+	// first page. The part's own bookmarks are nested under it.
+	Bookmark string
+}
+
+// Merge concatenates parts in order and writes the result to outputPath.
+// When any part has a Bookmark, the merged document's outline is replaced
+// by one entry per bookmarked part.
+func Merge(ctx context.Context, parts []MergePart, outputPath string) error {
+	if len(parts) < 2 {
+		return ErrNothingToMerge
+	}
+
+	var bookmarks []pdfcpu.Bookmark
+	inputs := make([]io.ReadSeeker, 0, len(parts))
+	page := 1
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pages, err := api.PageCount(part.PDF, pdfConfig())
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidPDF, part.Name, err)
+		}
+
+		if part.Bookmark != "" {
+			if _, err := part.PDF.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			bookmark := pdfcpu.Bookmark{Title: part.Bookmark, PageFrom: page}
+			// A part without an outline, or with one pdfcpu can't read,
+			// still gets its own bookmark.
+			if kids, err := api.Bookmarks(part.PDF, pdfConfig()); err == nil {
+				bookmark.Kids = offsetBookmarks(kids, page-1)
+			}
+			bookmarks = append(bookmarks, bookmark)
+		}
+
+		if _, err := part.PDF.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		inputs = append(inputs, part.PDF)
+		page += pages
+	}
+
+	var merged bytes.Buffer
+	if err := api.MergeRaw(inputs, &merged, false, pdfConfig()); err != nil {
+		return fmt.Errorf("failed to merge PDFs: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	out := merged.Bytes()
+	if len(bookmarks) > 0 {
+		var buf bytes.Buffer
+		if err := api.AddBookmarks(bytes.NewReader(out), &buf, bookmarks, true, pdfConfig()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBookmark, err)
+		}
+		out = buf.Bytes()
+	}
+
+	return os.WriteFile(outputPath, out, 0644)
+}
+
+func offsetBookmarks(bookmarks []pdfcpu.Bookmark, offset int) []pdfcpu.Bookmark {
+	out := make([]pdfcpu.Bookmark, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		out = append(out, pdfcpu.Bookmark{
+			Title:    b.Title,
+			PageFrom: b.PageFrom + offset,
+			Bold:     b.Bold,
+			Italic:   b.Italic,
+			Color:    b.Color,
+			Kids:     offsetBookmarks(b.Kids, offset),
+		})
+	}
+	return out
+}
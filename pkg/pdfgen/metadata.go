@@ -0,0 +1,211 @@
+package pdfgen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata is written into both the document information dictionary and
+// the XMP packet of PDF output. Empty fields keep what Chrome wrote, except
+// Title, which falls back to the document's <title>.
+type Metadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+	Creator  string
+	Producer string
+
+	// Custom properties show up as custom document properties in PDF
+	// readers. Names must be XML names such as "InvoiceNumber".
+	Custom map[string]string
+}
+
+var customPropertyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]{0,63}$`)
+
+var standardInfoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate", "Trapped"}
+
+func (m *Metadata) Validate() error {
+	for name := range m.Custom {
+		if !customPropertyPattern.MatchString(name) {
+			return fmt.Errorf("%w: custom property name %q", ErrInvalidMetadata, name)
+		}
+		if slices.Contains(standardInfoKeys, name) {
+			return fmt.Errorf("%w: %q is a standard field, not a custom property", ErrInvalidMetadata, name)
+		}
+	}
+	return nil
+}
+
+// writeMetadata returns pdf with m appended as an incremental update, so
+// Chrome's output is left byte for byte intact. Rewriting the whole file
+// would also have pdfcpu stamp itself as the Producer.
+func writeMetadata(pdf []byte, m *Metadata, documentTitle string) ([]byte, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(pdf), pdfConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	fields := m.fields(documentTitle)
+	now := time.Now()
+
+	info := types.NewDict()
+	if ctx.Info != nil {
+		if existing, err := ctx.DereferenceDict(*ctx.Info); err == nil && existing != nil {
+			info = existing
+		}
+	}
+	for _, f := range fields {
+		s, err := types.EscapedUTF16String(f.value)
+		if err != nil {
+			return nil, err
+		}
+		info[f.name] = types.StringLiteral(*s)
+	}
+	info["ModDate"] = types.StringLiteral(types.DateString(now))
+	if _, ok := info["CreationDate"]; !ok {
+		info["CreationDate"] = types.StringLiteral(types.DateString(now))
+	}
+
+	if ctx.Info == nil {
+		if ctx.Info, err = ctx.IndRefForNewObject(info); err != nil {
+			return nil, err
+		}
+	}
+	ctx.Write.IncrementWithObjNr(ctx.Info.ObjectNumber.Value())
+
+	xmp := types.StreamDict{Dict: types.NewDict(), Content: m.xmp(fields, now)}
+	xmp.InsertName("Type", "Metadata")
+	xmp.InsertName("Subtype", "XML")
+	if err := xmp.Encode(); err != nil {
+		return nil, err
+	}
+	xmpRef, err := ctx.IndRefForNewObject(xmp)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Write.IncrementWithObjNr(xmpRef.ObjectNumber.Value())
+
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	catalog["Metadata"] = *xmpRef
+	ctx.Write.IncrementWithObjNr(ctx.Root.ObjectNumber.Value())
+
+	return appendIncrement(ctx, pdf)
+}
+
+// appendIncrement writes the objects queued with IncrementWithObjNr after
+// pdf, the bytes ctx was read from.
+func appendIncrement(ctx *model.Context, pdf []byte) ([]byte, error) {
+	ctx.Write.Increment = true
+	ctx.Write.Offset = int64(len(pdf))
+
+	out := bytes.NewBuffer(slices.Clip(pdf))
+	if !bytes.HasSuffix(pdf, []byte("\n")) {
+		out.WriteByte('\n')
+		ctx.Write.Offset++
+	}
+	if err := api.WriteIncrement(ctx, out); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+type metadataField struct {
+	name  string
+	value string
+}
+
+func (m *Metadata) fields(documentTitle string) []metadataField {
+	title := m.Title
+	if title == "" {
+		title = documentTitle
+	}
+
+	var fields []metadataField
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, metadataField{name, value})
+		}
+	}
+	add("Title", title)
+	add("Author", m.Author)
+	add("Subject", m.Subject)
+	add("Keywords", strings.Join(m.Keywords, ", "))
+	add("Creator", m.Creator)
+	add("Producer", m.Producer)
+
+	names := make([]string, 0, len(m.Custom))
+	for name := range m.Custom {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		add(name, m.Custom[name])
+	}
+	return fields
+}
+
+func (m *Metadata) xmp(fields []metadataField, now time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about=""` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:pdf="http://ns.adobe.com/pdf/1.3/"` +
+		` xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+		` xmlns:pdfx="http://ns.adobe.com/pdfx/1.3/">` + "\n")
+
+	date := now.Format(time.RFC3339)
+	fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n<xmp:MetadataDate>%s</xmp:MetadataDate>\n", date, date)
+
+	for _, f := range fields {
+		switch f.name {
+		case "Title":
+			fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlEscape(f.value))
+		case "Author":
+			fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", xmlEscape(f.value))
+		case "Subject":
+			fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", xmlEscape(f.value))
+		case "Keywords":
+			fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n<dc:subject><rdf:Bag>", xmlEscape(f.value))
+			for _, k := range m.Keywords {
+				fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", xmlEscape(k))
+			}
+			b.WriteString("</rdf:Bag></dc:subject>\n")
+		case "Creator":
+			fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlEscape(f.value))
+		case "Producer":
+			fmt.Fprintf(&b, "<pdf:Producer>%s</pdf:Producer>\n", xmlEscape(f.value))
+		default:
+			fmt.Fprintf(&b, "<pdfx:%s>%s</pdfx:%s>\n", f.name, xmlEscape(f.value), f.name)
+		}
+	}
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// Padding lets other tools edit the packet in place.
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
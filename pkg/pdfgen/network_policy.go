@@ -0,0 +1,251 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidNetworkPolicy = errors.New("invalid network policy")
+
+// ResourceType is a kind of request as Chrome classifies it.
+type ResourceType string
+
+const (
+	ResourceDocument    ResourceType = "document"
+	ResourceStylesheet  ResourceType = "stylesheet"
+	ResourceImage       ResourceType = "image"
+	ResourceMedia       ResourceType = "media"
+	ResourceFont        ResourceType = "font"
+	ResourceScript      ResourceType = "script"
+	ResourceXHR         ResourceType = "xhr"
+	ResourceFetch       ResourceType = "fetch"
+	ResourceWebSocket   ResourceType = "websocket"
+	ResourceEventSource ResourceType = "eventsource"
+	ResourceManifest    ResourceType = "manifest"
+	ResourceOther       ResourceType = "other"
+)
+
+var resourceTypes = map[ResourceType]bool{
+	ResourceDocument: true, ResourceStylesheet: true, ResourceImage: true, ResourceMedia: true,
+	ResourceFont: true, ResourceScript: true, ResourceXHR: true, ResourceFetch: true,
+	ResourceWebSocket: true, ResourceEventSource: true, ResourceManifest: true, ResourceOther: true,
+}
+
+var webSocketSchemes = []string{"ws", "wss"}
+
+// trackerHosts are blocked, with their subdomains, by BlockTrackers.
+var trackerHosts = []string{
+	"google-analytics.com",
+	"googletagmanager.com",
+	"googleadservices.com",
+	"googlesyndication.com",
+	"doubleclick.net",
+	"connect.facebook.net",
+	"analytics.tiktok.com",
+	"static.ads-twitter.com",
+	"bat.bing.com",
+	"clarity.ms",
+	"hotjar.com",
+	"fullstory.com",
+	"mixpanel.com",
+	"amplitude.com",
+	"cdn.segment.com",
+	"api.segment.io",
+	"scorecardresearch.com",
+	"quantserve.com",
+}
+
+// NetworkPolicy limits what a render may fetch. Host patterns are either a
+// host name or "*.example.com", which matches example.com and all of its
+// subdomains. Requests answered locally (asset directory, font library) are
+// not subject to the policy.
+type NetworkPolicy struct {
+	AllowHosts         []string // when set, only these hosts are reachable
+	BlockHosts         []string
+	BlockResourceTypes []ResourceType
+	BlockTrackers      bool
+	Offline            bool // block every request that would leave the machine
+
+	// MaxDownloadBytes is a soft limit: once reached, further requests are
+	// blocked, but responses already being received are read to the end.
+	MaxDownloadBytes int64
+}
+
+func (p *NetworkPolicy) Validate() error {
+	for _, patterns := range [][]string{p.AllowHosts, p.BlockHosts} {
+		for _, pattern := range patterns {
+			if !validHostPattern(pattern) {
+				return fmt.Errorf("%w: host pattern %q", ErrInvalidNetworkPolicy, pattern)
+			}
+		}
+	}
+	for _, t := range p.BlockResourceTypes {
+		if !resourceTypes[t] {
+			return fmt.Errorf("%w: resource type %q", ErrInvalidNetworkPolicy, t)
+		}
+	}
+	if p.MaxDownloadBytes < 0 {
+		return fmt.Errorf("%w: max download size must not be negative", ErrInvalidNetworkPolicy)
+	}
+	return nil
+}
+
+func validHostPattern(pattern string) bool {
+	host := strings.TrimPrefix(pattern, "*.")
+	return host != "" && !strings.ContainsAny(host, "*/:@ ")
+}
+
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+func matchAnyHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if matchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyGuard enforces a NetworkPolicy for one render and records what it
+// blocks.
+type policyGuard struct {
+	policy     *NetworkPolicy
+	rec        *diagnosticsRecorder
+	downloaded atomic.Int64
+}
+
+func newPolicyGuard(policy *NetworkPolicy, rec *diagnosticsRecorder) *policyGuard {
+	return &policyGuard{policy: policy, rec: rec}
+}
+
+// track counts response bytes so MaxDownloadBytes can be enforced.
+func (g *policyGuard) track() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if g.policy.MaxDownloadBytes == 0 {
+			return nil
+		}
+		chromedp.ListenTarget(ctx, func(ev any) {
+			if ev, ok := ev.(*network.EventDataReceived); ok {
+				g.downloaded.Add(max(ev.EncodedDataLength, ev.DataLength))
+			}
+		})
+		return nil
+	})
+}
+
+// blockWebSockets covers what the Fetch domain never pauses: WebSocket
+// handshakes. They are blocked by URL pattern instead, entirely when the
+// render is offline, blocks the type or has an allow list, which patterns
+// can't express, and otherwise for blocked hosts and trackers.
+func (g *policyGuard) blockWebSockets() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		patterns := g.webSocketPatterns()
+		if len(patterns) == 0 {
+			return nil
+		}
+		return network.SetBlockedURLs(patterns).Do(ctx)
+	})
+}
+
+func (g *policyGuard) webSocketPatterns() []string {
+	p := g.policy
+	if p.Offline || len(p.AllowHosts) > 0 || slices.Contains(p.BlockResourceTypes, ResourceWebSocket) {
+		return webSocketURLPatterns("*")
+	}
+
+	var patterns []string
+	for _, host := range p.BlockHosts {
+		patterns = append(patterns, webSocketURLPatterns(strings.ToLower(host))...)
+	}
+	if p.BlockTrackers {
+		for _, tracker := range trackerHosts {
+			patterns = append(patterns, webSocketURLPatterns("*."+tracker)...)
+		}
+	}
+	return patterns
+}
+
+// webSocketURLPatterns turns a host pattern into Chrome URL patterns for
+// WebSockets to it, with or without a port.
+func webSocketURLPatterns(host string) []string {
+	hosts := []string{host}
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		hosts = append(hosts, suffix)
+	}
+	var patterns []string
+	for _, scheme := range webSocketSchemes {
+		for _, h := range hosts {
+			if h == "*" {
+				patterns = append(patterns, scheme+"://*")
+				continue
+			}
+			patterns = append(patterns, scheme+"://"+h+"/*", scheme+"://"+h+":*")
+		}
+	}
+	return patterns
+}
+
+func (g *policyGuard) rule(ev *fetch.EventRequestPaused) *requestDecision {
+	reason, failReason := g.check(ev)
+	if reason == "" {
+		return nil
+	}
+	g.rec.blocked(ev.Request.URL, strings.ToLower(string(ev.ResourceType)), reason)
+	return &requestDecision{FailReason: failReason}
+}
+
+func (g *policyGuard) check(ev *fetch.EventRequestPaused) (string, network.ErrorReason) {
+	p := g.policy
+	if p.Offline {
+		return "offline", network.ErrorReasonInternetDisconnected
+	}
+
+	for _, t := range p.BlockResourceTypes {
+		if strings.EqualFold(string(t), string(ev.ResourceType)) {
+			return "resource type " + string(t) + " is blocked", network.ErrorReasonBlockedByClient
+		}
+	}
+
+	u, err := url.Parse(ev.Request.URL)
+	if err != nil {
+		return "invalid URL", network.ErrorReasonBlockedByClient
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case len(p.AllowHosts) > 0 && !matchAnyHost(p.AllowHosts, host):
+		return "host is not in the allow list", network.ErrorReasonBlockedByClient
+	case matchAnyHost(p.BlockHosts, host):
+		return "host is blocked", network.ErrorReasonBlockedByClient
+	case p.BlockTrackers && isTracker(host):
+		return "tracker", network.ErrorReasonBlockedByClient
+	}
+
+	if p.MaxDownloadBytes > 0 && g.downloaded.Load() >= p.MaxDownloadBytes {
+		return fmt.Sprintf("download limit of %d bytes reached", p.MaxDownloadBytes), network.ErrorReasonBlockedByClient
+	}
+	return "", ""
+}
+
+func isTracker(host string) bool {
+	for _, tracker := range trackerHosts {
+		if host == tracker || strings.HasSuffix(host, "."+tracker) {
+			return true
+		}
+	}
+	return false
+}
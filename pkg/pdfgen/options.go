@@ -1,20 +1,23 @@
 package pdfgen
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/chromedp/cdproto/page"
 )
 
+// PageSize dimensions are in inches.
 type PageSize struct {
+	Name   string
 	Width  float64
 	Height float64
 }
 
 var (
-	PageSizeA4     = PageSize{Width: 8.27, Height: 11.69}
-	PageSizeLetter = PageSize{Width: 8.5, Height: 11.0}
-	PageSizeLegal  = PageSize{Width: 8.5, Height: 14.0}
+	PageSizeA4     = PageSize{Name: "A4", Width: 8.27, Height: 11.69}
+	PageSizeLetter = PageSize{Name: "Letter", Width: 8.5, Height: 11.0}
+	PageSizeLegal  = PageSize{Name: "Legal", Width: 8.5, Height: 14.0}
 )
 
 type PrintOptions struct {
@@ -29,6 +32,53 @@ type PrintOptions struct {
 	PageRanges        string
 	GenerateTaggedPDF bool
 	WaitBeforePrint   time.Duration
+	WaitFor           []WaitStrategy
+
+	// PreferCSSPageSize lets the document's @page size and margins win over
+	// PageSize and the margin fields.
+	PreferCSSPageSize bool
+
+	Emulation Emulation
+
+	// Screenshot, when set, produces an image instead of a PDF.
+	Screenshot *ScreenshotOptions
+
+	Credentials *Credentials
+
+	// BaseURL is the address raw HTML is loaded under, so relative and
+	// root-relative links resolve against it. AssetDir serves that origin
+	// from a local directory; it defaults to the Generator's AssetRoot, or
+	// the file's directory for file sources.
+	BaseURL  string
+	AssetDir string
+
+	Network *NetworkPolicy
+
+	// AcceptStatusCodes lists 4xx and 5xx statuses a URL render may print
+	// instead of failing with an HTTPStatusError.
+	AcceptStatusCodes []int
+
+	// Watermarks are stamped onto PDF output after printing, in order.
+	Watermarks []Watermark
+
+	// Metadata is written into PDF output after printing.
+	Metadata *Metadata
+
+	// Encryption, when set, password-protects PDF output. It is applied
+	// last, after watermarks and metadata.
+	Encryption *Encryption
+
+	// Signature, when set, signs PDF output with the Generator's Signer.
+	// Nothing may change the document afterwards, so it can't be combined
+	// with Encryption.
+	Signature *Signature
+
+	// Inline templates win over snippets. Either one turns on Chrome's
+	// header/footer area, which lives inside the top and bottom margins.
+	HeaderTemplate string
+	FooterTemplate string
+	HeaderSnippet  string
+	FooterSnippet  string
 }
 
 func DefaultPrintOptions() *PrintOptions {
@@ -46,6 +96,94 @@ func DefaultPrintOptions() *PrintOptions {
 	}
 }
 
+func (o *PrintOptions) Validate() error {
+	for i, w := range o.WaitFor {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("wait_for[%d]: %w", i, err)
+		}
+	}
+	if err := o.Emulation.Validate(); err != nil {
+		return err
+	}
+	if o.Screenshot != nil {
+		if err := o.Screenshot.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.PageRanges != "" {
+		if _, err := ParsePageRanges(o.PageRanges); err != nil {
+			return err
+		}
+	}
+	if o.BaseURL != "" {
+		if err := validateBaseURL(o.BaseURL); err != nil {
+			return err
+		}
+	}
+	if err := validateStatusCodes(o.AcceptStatusCodes); err != nil {
+		return err
+	}
+	if len(o.Watermarks) > 0 && o.Screenshot != nil {
+		return fmt.Errorf("%w: watermarks only apply to PDF output", ErrInvalidWatermark)
+	}
+	for i := range o.Watermarks {
+		if err := o.Watermarks[i].Validate(); err != nil {
+			return fmt.Errorf("watermarks[%d]: %w", i, err)
+		}
+	}
+	if o.Metadata != nil {
+		if o.Screenshot != nil {
+			return fmt.Errorf("%w: metadata only applies to PDF output", ErrInvalidMetadata)
+		}
+		if err := o.Metadata.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Encryption != nil {
+		if o.Screenshot != nil {
+			return fmt.Errorf("%w: encryption only applies to PDF output", ErrInvalidEncryption)
+		}
+		if err := o.Encryption.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Signature != nil {
+		if o.Screenshot != nil {
+			return fmt.Errorf("%w: signatures only apply to PDF output", ErrInvalidSignature)
+		}
+		if o.Encryption != nil {
+			return fmt.Errorf("%w: signed PDFs can't also be encrypted", ErrInvalidSignature)
+		}
+		if err := o.Signature.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Network != nil {
+		if err := o.Network.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Credentials != nil {
+		if err := o.Credentials.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := resolveTemplate(o.HeaderTemplate, o.HeaderSnippet); err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	if _, err := resolveTemplate(o.FooterTemplate, o.FooterSnippet); err != nil {
+		return fmt.Errorf("footer: %w", err)
+	}
+	return nil
+}
+
+func (o *PrintOptions) OutputFormat() OutputFormat {
+	if o.Screenshot != nil {
+		return o.Screenshot.Format
+	}
+	return OutputPDF
+}
+
 func (o *PrintOptions) ToCDPParams() *page.PrintToPDFParams {
 	params := page.PrintToPDF().
 		WithPrintBackground(o.PrintBackground).
@@ -55,7 +193,8 @@ func (o *PrintOptions) ToCDPParams() *page.PrintToPDFParams {
 		WithMarginLeft(o.MarginLeft).
 		WithMarginRight(o.MarginRight).
 		WithScale(o.Scale).
-		WithGenerateTaggedPDF(o.GenerateTaggedPDF)
+		WithGenerateTaggedPDF(o.GenerateTaggedPDF).
+		WithPreferCSSPageSize(o.PreferCSSPageSize)
 
 	if o.PageSize.Width > 0 && o.PageSize.Height > 0 {
 		params = params.WithPaperWidth(o.PageSize.Width).WithPaperHeight(o.PageSize.Height)
@@ -65,5 +204,21 @@ func (o *PrintOptions) ToCDPParams() *page.PrintToPDFParams {
 		params = params.WithPageRanges(o.PageRanges)
 	}
 
+	// Unknown snippets are rejected by Validate; here they just print blank.
+	header, _ := resolveTemplate(o.HeaderTemplate, o.HeaderSnippet)
+	footer, _ := resolveTemplate(o.FooterTemplate, o.FooterSnippet)
+	if header != "" || footer != "" {
+		if header == "" {
+			header = emptyTemplate
+		}
+		if footer == "" {
+			footer = emptyTemplate
+		}
+		params = params.
+			WithDisplayHeaderFooter(true).
+			WithHeaderTemplate(header).
+			WithFooterTemplate(footer)
+	}
+
 	return params
 }
@@ -0,0 +1,154 @@
+package pdfgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownPageSize = errors.New("unknown page size")
+	ErrUnknownUnit     = errors.New("unknown unit")
+	ErrInvalidPageSize = errors.New("page width and height must be positive")
+)
+
+// Unit is a length unit accepted for page sizes and margins. Chrome works
+// in inches, so everything is converted with ToInches.
+type Unit string
+
+const (
+	UnitInch       Unit = "in"
+	UnitMillimeter Unit = "mm"
+	UnitCentimeter Unit = "cm"
+	UnitPixel      Unit = "px" // CSS pixel, 1/96 in
+	UnitPoint      Unit = "pt" // 1/72 in
+)
+
+func ParseUnit(s string) (Unit, error) {
+	switch u := Unit(strings.ToLower(strings.TrimSpace(s))); u {
+	case "":
+		return UnitInch, nil
+	case UnitInch, UnitMillimeter, UnitCentimeter, UnitPixel, UnitPoint:
+		return u, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownUnit, s)
+	}
+}
+
+func (u Unit) ToInches(v float64) float64 {
+	switch u {
+	case UnitMillimeter:
+		return v / 25.4
+	case UnitCentimeter:
+		return v / 2.54
+	case UnitPixel:
+		return v / 96
+	case UnitPoint:
+		return v / 72
+	default:
+		return v
+	}
+}
+
+// NewPageSize builds a custom page size from dimensions in unit.
+func NewPageSize(width, height float64, unit Unit) (PageSize, error) {
+	if width <= 0 || height <= 0 {
+		return PageSize{}, ErrInvalidPageSize
+	}
+	return PageSize{
+		Name:   "Custom",
+		Width:  unit.ToInches(width),
+		Height: unit.ToInches(height),
+	}, nil
+}
+
+func sizeMM(name string, width, height float64) PageSize {
+	return PageSize{Name: name, Width: width / 25.4, Height: height / 25.4}
+}
+
+func sizeIn(name string, width, height float64) PageSize {
+	return PageSize{Name: name, Width: width, Height: height}
+}
+
+var pageSizes = map[string]PageSize{}
+
+func init() {
+	for _, s := range []PageSize{
+		sizeMM("A0", 841, 1189), sizeMM("A1", 594, 841), sizeMM("A2", 420, 594), sizeMM("A3", 297, 420),
+		PageSizeA4, sizeMM("A5", 148, 210), sizeMM("A6", 105, 148), sizeMM("A7", 74, 105),
+		sizeMM("A8", 52, 74), sizeMM("A9", 37, 52), sizeMM("A10", 26, 37),
+
+		sizeMM("B0", 1000, 1414), sizeMM("B1", 707, 1000), sizeMM("B2", 500, 707), sizeMM("B3", 353, 500),
+		sizeMM("B4", 250, 353), sizeMM("B5", 176, 250), sizeMM("B6", 125, 176), sizeMM("B7", 88, 125),
+		sizeMM("B8", 62, 88), sizeMM("B9", 44, 62), sizeMM("B10", 31, 44),
+
+		sizeMM("C0", 917, 1297), sizeMM("C1", 648, 917), sizeMM("C2", 458, 648), sizeMM("C3", 324, 458),
+		sizeMM("C4", 229, 324), sizeMM("C5", 162, 229), sizeMM("C6", 114, 162), sizeMM("C7", 81, 114),
+		sizeMM("C8", 57, 81), sizeMM("C9", 40, 57), sizeMM("C10", 28, 40),
+
+		PageSizeLetter, PageSizeLegal,
+		sizeIn("Tabloid", 11, 17), sizeIn("Ledger", 17, 11), sizeIn("Executive", 7.25, 10.5),
+		sizeIn("HalfLetter", 5.5, 8.5),
+
+		sizeMM("EnvelopeDL", 110, 220), sizeMM("EnvelopeC4", 229, 324), sizeMM("EnvelopeC5", 162, 229),
+		sizeMM("EnvelopeC6", 114, 162), sizeIn("Envelope10", 4.125, 9.5), sizeIn("EnvelopeMonarch", 3.875, 7.5),
+
+		sizeIn("Label4x6", 4, 6), sizeIn("Label4x4", 4, 4), sizeIn("Label4x3", 4, 3),
+		sizeIn("Label4x2", 4, 2), sizeIn("Label2x1", 2, 1),
+	} {
+		pageSizes[strings.ToLower(s.Name)] = s
+	}
+}
+
+// LookupPageSize finds a named size, ignoring case. An empty name is A4.
+func LookupPageSize(name string) (PageSize, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return PageSizeA4, nil
+	}
+	size, ok := pageSizes[strings.ToLower(name)]
+	if !ok {
+		return PageSize{}, fmt.Errorf("%w: %q", ErrUnknownPageSize, name)
+	}
+	return size, nil
+}
+
+// pageSizeTolerance absorbs rounding between mm catalogue sizes and the
+// point values Chrome writes into the PDF.
+const pageSizeTolerance = 0.02
+
+func sameDimensions(a, b PageSize) bool {
+	return math.Abs(a.Width-b.Width) <= pageSizeTolerance && math.Abs(a.Height-b.Height) <= pageSizeTolerance
+}
+
+// MatchPageSize names a page of the given inch dimensions after the
+// catalogue entry it matches, or "Custom". An entry in the same orientation
+// wins over one that only matches rotated, so a Tabloid page isn't taken
+// for a Ledger one.
+func MatchPageSize(width, height float64) PageSize {
+	size := PageSize{Name: "Custom", Width: width, Height: height}
+	rotated := PageSize{Width: height, Height: width}
+
+	names := PageSizeNames()
+	for _, want := range []PageSize{size, rotated} {
+		for _, name := range names {
+			known := pageSizes[strings.ToLower(name)]
+			if sameDimensions(known, want) {
+				size.Name = known.Name
+				return size
+			}
+		}
+	}
+	return size
+}
+
+func PageSizeNames() []string {
+	names := make([]string, 0, len(pageSizes))
+	for _, s := range pageSizes {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
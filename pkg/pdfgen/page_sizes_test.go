@@ -0,0 +1,28 @@
+package pdfgen
+
+import "testing"
+
+func TestMatchPageSize(t *testing.T) {
+	tests := []struct {
+		width, height float64
+		want          string
+	}{
+		{8.27, 11.69, "A4"},
+		{11.69, 8.27, "A4"},
+		{8.5, 11, "Letter"},
+		{11, 8.5, "Letter"},
+		{11, 17, "Tabloid"},
+		{17, 11, "Ledger"},
+		{8.27 + pageSizeTolerance/2, 11.69, "A4"},
+		{7, 7, "Custom"},
+	}
+	for _, tt := range tests {
+		got := MatchPageSize(tt.width, tt.height)
+		if got.Name != tt.want {
+			t.Errorf("MatchPageSize(%v, %v) = %q, want %q", tt.width, tt.height, got.Name, tt.want)
+		}
+		if got.Width != tt.width || got.Height != tt.height {
+			t.Errorf("MatchPageSize(%v, %v) changed the dimensions to %vx%v", tt.width, tt.height, got.Width, got.Height)
+		}
+	}
+}
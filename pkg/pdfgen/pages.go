@@ -0,0 +1,252 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var (
+	ErrInvalidPageRange = errors.New("invalid page range")
+	ErrInvalidRotation  = errors.New("rotation must be a multiple of 90 degrees")
+)
+
+// MaxPageRanges is the most comma-separated parts ParsePageRanges accepts.
+const MaxPageRanges = 10000
+
+// PageRange is an inclusive, 1-based range of pages. A zero From means the
+// first page and a zero To the last.
+type PageRange struct {
+	From int
+	To   int
+}
+
+func (r PageRange) String() string {
+	switch {
+	case r.From == r.To:
+		return strconv.Itoa(r.From)
+	case r.To == 0:
+		return fmt.Sprintf("%d-", max(r.From, 1))
+	default:
+		return fmt.Sprintf("%d-%d", max(r.From, 1), r.To)
+	}
+}
+
+// ParsePageRanges parses the same syntax Chrome uses for PageRanges:
+// comma-separated pages and ranges such as "1-5, 8, 11-", where "-3" means
+// up to page 3 and "11-" from page 11 to the end.
+func ParsePageRanges(s string) ([]PageRange, error) {
+	if strings.Count(s, ",") >= MaxPageRanges {
+		return nil, fmt.Errorf("%w: more than %d ranges", ErrInvalidPageRange, MaxPageRanges)
+	}
+
+	var ranges []PageRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r, err := parsePageRange(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPageRange, part)
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("%w: no pages in %q", ErrInvalidPageRange, s)
+	}
+	return ranges, nil
+}
+
+func parsePageRange(s string) (PageRange, error) {
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		n, err := parsePageNumber(from, false)
+		return PageRange{From: n, To: n}, err
+	}
+
+	var r PageRange
+	var err error
+	if r.From, err = parsePageNumber(from, true); err != nil {
+		return r, err
+	}
+	if r.To, err = parsePageNumber(to, true); err != nil {
+		return r, err
+	}
+	if r.From == 0 && r.To == 0 || r.To != 0 && r.From > r.To {
+		return r, ErrInvalidPageRange
+	}
+	return r, nil
+}
+
+func parsePageNumber(s string, optional bool) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" && optional {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, ErrInvalidPageRange
+	}
+	return n, nil
+}
+
+// ExpandPageRanges lists the pages ranges select in a document of
+// pageCount pages, in the order given. A page selected by more than one
+// range is listed once, where it first appears.
+func ExpandPageRanges(ranges []PageRange, pageCount int) ([]int, error) {
+	seen := make([]bool, pageCount+1)
+	var pages []int
+	for _, r := range ranges {
+		from, to := max(r.From, 1), r.To
+		if to == 0 {
+			to = pageCount
+		}
+		if from > pageCount || to > pageCount {
+			return nil, fmt.Errorf("%w: %s is outside the document's %d pages", ErrInvalidPageRange, r, pageCount)
+		}
+		for p := from; p <= to && len(pages) < pageCount; p++ {
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+		}
+	}
+	return pages, nil
+}
+
+// PageCount returns the number of pages in the PDF at path.
+func PageCount(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := api.PageCount(f, pdfConfig())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	return n, nil
+}
+
+// SelectPages writes a document made of the given pages of the PDF at
+// inputPath, in that order. It covers extracting, reordering and splitting.
+func SelectPages(ctx context.Context, inputPath string, pages []int, outputPath string) error {
+	doc, err := ReadDocument(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	return doc.SelectPages(ctx, pages, outputPath)
+}
+
+// Document is a PDF read once for several page selections, such as the
+// parts of a split.
+type Document struct {
+	pdf *model.Context
+}
+
+func ReadDocument(ctx context.Context, path string) (*Document, error) {
+	pdf, err := readPDF(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{pdf: pdf}, nil
+}
+
+// SelectPages is the package-level SelectPages for an already read
+// document.
+func (d *Document) SelectPages(ctx context.Context, pages []int, outputPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, p := range pages {
+		if p < 1 || p > d.pdf.PageCount {
+			return fmt.Errorf("%w: page %d is outside the document's %d pages", ErrInvalidPageRange, p, d.pdf.PageCount)
+		}
+	}
+	out, err := pdfcpu.ExtractPages(d.pdf, pages, false)
+	if err != nil {
+		return err
+	}
+	return writePDF(ctx, out, outputPath)
+}
+
+// RotatePages rotates the given pages, or every page if pages is empty,
+// clockwise by degrees.
+func RotatePages(ctx context.Context, inputPath string, degrees int, pages []int, outputPath string) error {
+	if degrees%90 != 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidRotation, degrees)
+	}
+	return transformPDF(ctx, inputPath, outputPath, func(pdf *model.Context) (*model.Context, error) {
+		selected := types.IntSet{}
+		for _, p := range pages {
+			if p < 1 || p > pdf.PageCount {
+				return nil, fmt.Errorf("%w: page %d is outside the document's %d pages", ErrInvalidPageRange, p, pdf.PageCount)
+			}
+			selected[p] = true
+		}
+		if len(pages) == 0 {
+			for p := 1; p <= pdf.PageCount; p++ {
+				selected[p] = true
+			}
+		}
+		return pdf, pdfcpu.RotatePages(pdf, selected, degrees)
+	})
+}
+
+// transformPDF reads the PDF at inputPath, applies fn and writes the
+// document fn returns to outputPath.
+func transformPDF(ctx context.Context, inputPath, outputPath string, fn func(*model.Context) (*model.Context, error)) error {
+	pdf, err := readPDF(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	out, err := fn(pdf)
+	if err != nil {
+		return err
+	}
+	return writePDF(ctx, out, outputPath)
+}
+
+func readPDF(ctx context.Context, path string) (*model.Context, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pdf, err := api.ReadValidateAndOptimize(f, pdfConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+func writePDF(ctx context.Context, pdf *model.Context, outputPath string) error {
+	var buf bytes.Buffer
+	if err := api.WriteContext(pdf, &buf); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, buf.Bytes(), 0644)
+}
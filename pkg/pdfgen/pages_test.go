@@ -0,0 +1,85 @@
+package pdfgen
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []PageRange
+		wantErr bool
+	}{
+		{in: "1", want: []PageRange{{1, 1}}},
+		{in: "1-5, 8, 11-", want: []PageRange{{1, 5}, {8, 8}, {11, 0}}},
+		{in: "-3", want: []PageRange{{0, 3}}},
+		{in: "11-", want: []PageRange{{11, 0}}},
+		{in: " 2 - 4 ,,7", want: []PageRange{{2, 4}, {7, 7}}},
+		{in: "3, 1-2", want: []PageRange{{3, 3}, {1, 2}}},
+		{in: "", wantErr: true},
+		{in: " , ", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "5-3", wantErr: true},
+		{in: "1-2-3", wantErr: true},
+		{in: "a", wantErr: true},
+		{in: "-0", wantErr: true},
+		{in: "1,-1", want: []PageRange{{1, 1}, {0, 1}}},
+		{in: strings.Repeat("1,", MaxPageRanges), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePageRanges(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPageRange) {
+				t.Errorf("ParsePageRanges(%.20q) error = %v, want ErrInvalidPageRange", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePageRanges(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandPageRanges(t *testing.T) {
+	tests := []struct {
+		in        string
+		pageCount int
+		want      []int
+		wantErr   bool
+	}{
+		{in: "1-3", pageCount: 5, want: []int{1, 2, 3}},
+		{in: "-3", pageCount: 5, want: []int{1, 2, 3}},
+		{in: "4-", pageCount: 5, want: []int{4, 5}},
+		{in: "5-", pageCount: 5, want: []int{5}},
+		{in: "3, 1-2, 4-", pageCount: 5, want: []int{3, 1, 2, 4, 5}},
+		{in: "2, 2", pageCount: 5, want: []int{2}},
+		{in: "1-4, 2-5", pageCount: 5, want: []int{1, 2, 3, 4, 5}},
+		{in: "3-5, 1-4", pageCount: 5, want: []int{3, 4, 5, 1, 2}},
+		{in: "1-5, 1", pageCount: 5, want: []int{1, 2, 3, 4, 5}},
+		{in: "1-, 1-", pageCount: 5, want: []int{1, 2, 3, 4, 5}},
+		{in: "-2, 2-3, 3-", pageCount: 5, want: []int{1, 2, 3, 4, 5}},
+		{in: "6", pageCount: 5, wantErr: true},
+		{in: "6-", pageCount: 5, wantErr: true},
+		{in: "-6", pageCount: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		ranges, err := ParsePageRanges(tt.in)
+		if err != nil {
+			t.Fatalf("ParsePageRanges(%q): %v", tt.in, err)
+		}
+		got, err := ExpandPageRanges(ranges, tt.pageCount)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPageRange) {
+				t.Errorf("ExpandPageRanges(%q, %d) error = %v, want ErrInvalidPageRange", tt.in, tt.pageCount, err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("ExpandPageRanges(%q, %d) = %v, %v, want %v", tt.in, tt.pageCount, got, err, tt.want)
+		}
+	}
+}
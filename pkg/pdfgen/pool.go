@@ -0,0 +1,384 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrGeneratorClosed = errors.New("generator is closed")
+
+// PoolConfig controls how many Chrome processes and tabs a Generator keeps
+// alive between renders.
+type PoolConfig struct {
+	Browsers       int // number of Chrome processes
+	TabsPerBrowser int // concurrent tabs per process
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Browsers:       2,
+		TabsPerBrowser: 4,
+	}
+}
+
+type browserInstance struct {
+	index       int
+	ctx         context.Context
+	cancel      context.CancelFunc
+	allocCancel context.CancelFunc
+	tabs        int
+
+	// ready is closed once the browser has started or failed to, which err
+	// says. Until then only index and tabs are set.
+	ready chan struct{}
+	err   error
+}
+
+// aliveLocked reports whether b is starting or running.
+func (b *browserInstance) aliveLocked() bool {
+	select {
+	case <-b.ready:
+		return b.err == nil && b.ctx.Err() == nil
+	default:
+		return true
+	}
+}
+
+func (b *browserInstance) stop() {
+	if b.cancel != nil {
+		b.cancel()
+		b.allocCancel()
+	}
+}
+
+type tab struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	browser *browserInstance
+}
+
+type browserPool struct {
+	cfg    PoolConfig
+	chrome ChromeConfig
+	guard  *URLGuard
+	slots  chan struct{}
+
+	// proxy carries the traffic of launched browsers when there is a
+	// guard. It is started with the first browser.
+	proxyMu sync.Mutex
+	proxy   *guardProxy
+
+	mu       sync.Mutex
+	idle     []*tab
+	browsers []*browserInstance
+	closed   bool
+}
+
+func newBrowserPool(cfg PoolConfig, chrome ChromeConfig, guard *URLGuard) *browserPool {
+	defaults := DefaultPoolConfig()
+	if cfg.Browsers <= 0 {
+		cfg.Browsers = defaults.Browsers
+	}
+	if cfg.TabsPerBrowser <= 0 {
+		cfg.TabsPerBrowser = defaults.TabsPerBrowser
+	}
+
+	return &browserPool{
+		cfg:    cfg,
+		chrome: chrome,
+		guard:  guard,
+		slots:  make(chan struct{}, cfg.Browsers*cfg.TabsPerBrowser),
+	}
+}
+
+// acquire blocks until a tab is free or ctx is done. Browsers are started
+// lazily, so the first renders pay the Chrome startup cost.
+func (p *browserPool) acquire(ctx context.Context) (*tab, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	t, err := p.checkout(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkout hands out an idle tab or opens one. Starting a browser takes
+// seconds, so the pool only reserves a tab on it under the lock; starting
+// it and opening the tab happen outside.
+func (p *browserPool) checkout(ctx context.Context) (*tab, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrGeneratorClosed
+		}
+		if t := p.popIdleLocked(); t != nil {
+			p.mu.Unlock()
+			return t, nil
+		}
+		b, start := p.pickBrowserLocked()
+		b.tabs++
+		p.mu.Unlock()
+
+		if start {
+			p.startBrowser(ctx, b)
+		} else {
+			select {
+			case <-b.ready:
+			case <-ctx.Done():
+				p.unreserve(b)
+				return nil, ctx.Err()
+			}
+		}
+		if b.err != nil {
+			p.unreserve(b)
+			if start {
+				return nil, b.err
+			}
+			// Another caller's start failed, perhaps only because it gave
+			// up waiting; try again.
+			continue
+		}
+
+		tabCtx, tabCancel, err := p.openTab(b)
+		if err != nil {
+			p.unreserve(b)
+			return nil, err
+		}
+		return &tab{ctx: tabCtx, cancel: tabCancel, browser: b}, nil
+	}
+}
+
+// openTab opens a tab on b in a browser context of its own, so cookies,
+// storage, caches and service workers never leak between jobs running side
+// by side on the same process.
+func (p *browserPool) openTab(b *browserInstance) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := chromedp.NewContext(b.ctx, chromedp.WithNewBrowserContext())
+	if err := chromedp.Run(ctx, p.tabSetup()); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to open browser tab: %w", err)
+	}
+	return ctx, cancel, nil
+}
+
+func (p *browserPool) popIdleLocked() *tab {
+	for len(p.idle) > 0 {
+		t := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if t.ctx.Err() == nil && t.browser.ctx.Err() == nil {
+			return t
+		}
+		p.dropTabLocked(t)
+	}
+	return nil
+}
+
+func (p *browserPool) unreserve(b *browserInstance) {
+	p.mu.Lock()
+	b.tabs--
+	p.mu.Unlock()
+}
+
+// pickBrowserLocked returns the browser a new tab should go on. With start
+// set it is a new one the caller must start.
+func (p *browserPool) pickBrowserLocked() (b *browserInstance, start bool) {
+	alive := p.browsers[:0]
+	for _, b := range p.browsers {
+		if b.aliveLocked() {
+			alive = append(alive, b)
+		} else {
+			b.stop()
+		}
+	}
+	p.browsers = alive
+
+	var best *browserInstance
+	for _, b := range p.browsers {
+		if b.tabs < p.cfg.TabsPerBrowser && (best == nil || b.tabs < best.tabs) {
+			best = b
+		}
+	}
+	if best != nil && (best.tabs == 0 || len(p.browsers) >= p.cfg.Browsers) {
+		return best, false
+	}
+	if len(p.browsers) >= p.cfg.Browsers {
+		// slots admits at most Browsers*TabsPerBrowser callers and the
+		// caller holds one without a tab yet, so a browser has room.
+		panic("pdfgen: browser pool has no free tab although a slot was acquired")
+	}
+
+	b = &browserInstance{index: p.freeIndexLocked(), ready: make(chan struct{})}
+	p.browsers = append(p.browsers, b)
+	return b, true
+}
+
+// freeIndexLocked returns the lowest slot number no live browser uses, so a
+// restarted browser takes over its predecessor's profile directory.
+func (p *browserPool) freeIndexLocked() int {
+	for index := 0; ; index++ {
+		used := false
+		for _, b := range p.browsers {
+			if b.index == index {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return index
+		}
+	}
+}
+
+// startBrowser starts the reserved browser b and publishes the outcome.
+// The browser outlives ctx, which only bounds how long the caller waits for
+// it to come up.
+func (p *browserPool) startBrowser(ctx context.Context, b *browserInstance) {
+	browserCtx, cancel, allocCancel, err := p.launch(ctx, b.index)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil && p.closed {
+		cancel()
+		allocCancel()
+		err = ErrGeneratorClosed
+	}
+	if err == nil {
+		b.ctx, b.cancel, b.allocCancel = browserCtx, cancel, allocCancel
+	}
+	b.err = err
+	close(b.ready)
+}
+
+func (p *browserPool) launch(ctx context.Context, index int) (context.Context, context.CancelFunc, context.CancelFunc, error) {
+	if err := p.chrome.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	proxyURL, err := p.guardProxyURL()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	allocCtx, allocCancel := p.chrome.newAllocator(index, proxyURL)
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+
+	started := make(chan error, 1)
+	go func() { started <- chromedp.Run(browserCtx) }()
+	select {
+	case err = <-started:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		allocCancel()
+		if p.chrome.remote() {
+			return nil, nil, nil, fmt.Errorf("failed to connect to browser at %s: %w", p.chrome.RemoteURL, err)
+		}
+		return nil, nil, nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+	return browserCtx, cancel, allocCancel, nil
+}
+
+// guardProxyURL returns the address of the URL guard's proxy, starting it
+// if need be. A remote browser can't be pointed at it, so there it is
+// empty and only the tab's own requests are checked.
+func (p *browserPool) guardProxyURL() (string, error) {
+	if p.guard == nil || p.chrome.remote() {
+		return "", nil
+	}
+
+	p.proxyMu.Lock()
+	defer p.proxyMu.Unlock()
+	if p.proxy == nil {
+		proxy, err := startGuardProxy(p.guard, p.chrome.ProxyServer)
+		if err != nil {
+			return "", err
+		}
+		p.proxy = proxy
+	}
+	return p.proxy.url(), nil
+}
+
+// tabSetup applies per-tab settings that a remote browser cannot take as
+// command-line flags.
+func (p *browserPool) tabSetup() chromedp.Action {
+	if p.chrome.remote() && p.chrome.UserAgent != "" {
+		return emulation.SetUserAgentOverride(p.chrome.UserAgent)
+	}
+	return chromedp.Tasks{}
+}
+
+// release returns a tab to the pool after resetting it. Tabs that are not
+// reusable, fail to reset or belong to a dead browser are closed instead.
+func (p *browserPool) release(t *tab, reusable bool) {
+	defer func() { <-p.slots }()
+
+	if reusable {
+		reusable = p.reset(t) == nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || !reusable || t.ctx.Err() != nil || t.browser.ctx.Err() != nil {
+		p.dropTabLocked(t)
+		return
+	}
+	p.idle = append(p.idle, t)
+}
+
+// reset moves t to a fresh tab in a new browser context. Cancelling the
+// old one disposes its browser context with whatever the job left in it:
+// cookies, local and session storage, IndexedDB, Cache Storage, service
+// workers, interception, blocked URLs and emulation overrides.
+func (p *browserPool) reset(t *tab) error {
+	ctx, cancel, err := p.openTab(t.browser)
+	if err != nil {
+		return err
+	}
+	t.cancel()
+	t.ctx, t.cancel = ctx, cancel
+	return nil
+}
+
+func (p *browserPool) dropTabLocked(t *tab) {
+	t.cancel()
+	t.browser.tabs--
+}
+
+func (p *browserPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	browsers := p.browsers
+	p.idle = nil
+	p.browsers = nil
+	p.mu.Unlock()
+
+	for _, t := range idle {
+		t.cancel()
+	}
+	// Browsers still starting are stopped by startBrowser once it sees the
+	// pool is closed.
+	for _, b := range browsers {
+		b.stop()
+	}
+
+	p.proxyMu.Lock()
+	if p.proxy != nil {
+		p.proxy.close()
+		p.proxy = nil
+	}
+	p.proxyMu.Unlock()
+}
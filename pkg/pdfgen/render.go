@@ -0,0 +1,392 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdpio "github.com/chromedp/cdproto/io"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	ErrInvalidURL    = errors.New("URL cannot be empty")
+	ErrEmptyDocument = errors.New("generation resulted in empty file")
+)
+
+const streamChunkSize = 1 << 20
+
+type SourceType int
+
+const (
+	SourceHTML SourceType = iota
+	SourceURL
+	SourceFile
+)
+
+// Source is the document a render starts from: raw HTML, a URL to navigate
+// to, or a path to an HTML file on local disk.
+type Source struct {
+	Type  SourceType
+	Value string
+}
+
+func HTMLSource(html string) Source {
+	return Source{Type: SourceHTML, Value: html}
+}
+
+func URLSource(url string) Source {
+	return Source{Type: SourceURL, Value: url}
+}
+
+func FileSource(path string) Source {
+	return Source{Type: SourceFile, Value: path}
+}
+
+// Render produces the PDF for source and returns it in memory.
+func (g *Generator) Render(ctx context.Context, source Source, opts *PrintOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := g.RenderTo(ctx, &buf, source, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderTo writes the PDF for source into w. A plain PDF or screenshot is
+// streamed as Chrome produces it, so if an error occurs part way through, w
+// may already hold a partial document. With watermarks, metadata,
+// encryption or a signature the whole PDF is buffered in memory, processed,
+// and only written once it is complete.
+func (g *Generator) RenderTo(ctx context.Context, w io.Writer, source Source, opts *PrintOptions) error {
+	_, err := g.RenderToResult(ctx, w, source, opts)
+	return err
+}
+
+// Result describes a finished render.
+type Result struct {
+	Format OutputFormat
+	Size   int64
+
+	// PageSize is read back from the first page of the output, so with
+	// PreferCSSPageSize it reflects the document's @page size if it won.
+	PageSize        PageSize
+	PageSizeFromCSS bool
+
+	// HTTPStatus is the status of the main document for URL renders.
+	HTTPStatus int
+
+	Diagnostics *Diagnostics
+}
+
+// RenderToResult is RenderTo that also reports what was produced. Once the
+// browser has been involved, the Result is returned even on error so its
+// Diagnostics can explain the failure.
+func (g *Generator) RenderToResult(ctx context.Context, w io.Writer, source Source, opts *PrintOptions) (*Result, error) {
+	if opts == nil {
+		opts = DefaultPrintOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Signature != nil && g.signer == nil {
+		return nil, ErrSigningNotConfigured
+	}
+
+	assetDir := opts.AssetDir
+	if source.Type == SourceFile {
+		htmlContent, err := os.ReadFile(source.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HTML file %s: %w", source.Value, err)
+		}
+		if assetDir == "" {
+			assetDir = filepath.Dir(source.Value)
+		}
+		source = HTMLSource(string(htmlContent))
+	}
+	if assetDir == "" {
+		assetDir = g.assetRoot
+	}
+
+	var (
+		load        chromedp.Action
+		defaultWait time.Duration
+		errPrefix   string
+	)
+
+	intercept := &interceptor{}
+	var (
+		targetURL  string
+		httpStatus int
+	)
+
+	switch source.Type {
+	case SourceHTML:
+		if err := g.validateHTML(source.Value); err != nil {
+			return nil, err
+		}
+		docURL := documentURL(opts.BaseURL, assetDir)
+		if docURL == "" {
+			load = loadHTML(source.Value)
+		} else {
+			intercept.addRule(serveDocument(docURL, source.Value))
+			if assetDir != "" {
+				root, err := os.OpenRoot(assetDir)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open asset directory: %w", err)
+				}
+				defer root.Close()
+				intercept.addRule(serveAssets(docURL, root))
+			}
+			load = chromedp.Navigate(docURL)
+			if opts.BaseURL != "" {
+				targetURL = docURL
+			}
+		}
+		defaultWait = 1 * time.Second
+		errPrefix = "failed to generate PDF"
+	case SourceURL:
+		if strings.TrimSpace(source.Value) == "" {
+			return nil, ErrInvalidURL
+		}
+		if err := g.CheckURL(ctx, source.Value); err != nil {
+			return nil, err
+		}
+		load = navigateChecked(source.Value, opts.AcceptStatusCodes, &httpStatus)
+		targetURL = source.Value
+		defaultWait = 2 * time.Second
+		errPrefix = "failed to generate PDF from URL"
+	default:
+		return nil, fmt.Errorf("unsupported source type: %d", source.Type)
+	}
+
+	// The fixed sleep is only a fallback; with explicit wait strategies it
+	// applies only when WaitBeforePrint is set on top of them.
+	waitTime := opts.WaitBeforePrint
+	if waitTime == 0 && len(opts.WaitFor) == 0 {
+		waitTime = defaultWait
+	}
+
+	cw := &countingWriter{w: w}
+	mb := &mediaBoxSniffer{w: cw}
+
+	rec := &diagnosticsRecorder{}
+	setup := chromedp.Tasks{rec.listen(), opts.Emulation.apply()}
+
+	// Rule order matters: whatever can be answered locally is, before the
+	// network policy sees what is left. The font rules are left out when
+	// the render can't need them; a page at a URL may load anything.
+	var injectFonts chromedp.Action = chromedp.Tasks{}
+	googleFonts := g.fonts != nil && (source.Type != SourceHTML || referencesGoogleFonts(source.Value))
+	if g.fonts != nil && g.fonts.hasFonts() {
+		intercept.addRule(g.fonts.serveFonts)
+		injectFonts = g.fonts.inject()
+	}
+	if googleFonts {
+		intercept.addRule(g.fonts.serveMirroredGoogleFonts)
+	}
+	if g.urlGuard != nil {
+		intercept.addRule(g.urlGuard.rule(ctx, rec))
+	}
+	if opts.Network != nil {
+		guard := newPolicyGuard(opts.Network, rec)
+		intercept.addRule(guard.rule)
+		setup = append(setup, guard.track(), guard.blockWebSockets())
+	}
+	if googleFonts {
+		intercept.addRule(g.fonts.fetchGoogleFonts(ctx))
+	}
+
+	if opts.Credentials != nil {
+		intercept.basicAuth = opts.Credentials.BasicAuth
+		intercept.headers = opts.Credentials.Headers
+		intercept.authOrigin = origin(targetURL)
+		setup = append(setup, opts.Credentials.apply(targetURL))
+	}
+	var output chromedp.Action
+	var (
+		printed       bytes.Buffer
+		documentTitle string
+	)
+	switch {
+	case opts.Screenshot != nil:
+		setup = append(setup, opts.Screenshot.setViewport())
+		output = opts.Screenshot.captureToWriter(cw)
+	case opts.Metadata != nil, len(opts.Watermarks) > 0, opts.Encryption != nil, opts.Signature != nil:
+		// The PDF is held back until it has been post-processed.
+		mb.w = &printed
+		output = chromedp.Tasks{
+			chromedp.Title(&documentTitle),
+			printToWriter(opts.ToCDPParams(), mb),
+		}
+	default:
+		output = printToWriter(opts.ToCDPParams(), mb)
+	}
+
+	err := g.withTab(ctx, func(ctx context.Context) error {
+		waiter := newPageWaiter(ctx, opts.WaitFor)
+		return chromedp.Run(ctx,
+			setup,
+			intercept.start(),
+			load,
+			injectFonts,
+			waiter,
+			chromedp.Sleep(waitTime),
+			output,
+		)
+	})
+	if err == nil && printed.Len() > 0 {
+		pdf := printed.Bytes()
+		if len(opts.Watermarks) > 0 {
+			pdf, err = watermarkPDF(pdf, opts.Watermarks)
+		}
+		if err == nil && opts.Metadata != nil {
+			pdf, err = writeMetadata(pdf, opts.Metadata, documentTitle)
+		}
+		if err == nil && opts.Encryption != nil {
+			pdf, err = encryptPDF(pdf, opts.Encryption)
+		}
+		if err == nil && opts.Signature != nil {
+			pdf, err = signPDF(pdf, g.signer, opts.Signature)
+		}
+		if err == nil {
+			_, err = cw.Write(pdf)
+		}
+	}
+	res := newResult(cw.n, mb, opts)
+	res.Diagnostics = rec.snapshot()
+	res.HTTPStatus = httpStatus
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+
+	if cw.n == 0 {
+		return res, ErrEmptyDocument
+	}
+
+	return res, nil
+}
+
+func newResult(size int64, mb *mediaBoxSniffer, opts *PrintOptions) *Result {
+	if opts.Screenshot != nil {
+		return &Result{Format: opts.Screenshot.Format, Size: size}
+	}
+
+	requested := opts.PageSize
+	if opts.Landscape {
+		requested.Width, requested.Height = requested.Height, requested.Width
+	}
+
+	res := &Result{Format: OutputPDF, Size: size, PageSize: requested}
+	if !mb.found {
+		return res
+	}
+
+	res.PageSize = MatchPageSize(mb.width, mb.height)
+	res.PageSizeFromCSS = opts.PreferCSSPageSize && !sameDimensions(res.PageSize, requested)
+	return res
+}
+
+func loadHTML(html string) chromedp.Action {
+	return chromedp.Tasks{
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			frameTree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			return page.SetDocumentContent(frameTree.Frame.ID, html).Do(ctx)
+		}),
+	}
+}
+
+// printToWriter asks Chrome to return the PDF as an IO stream and copies it
+// into w chunk by chunk instead of holding one large base64 string.
+func printToWriter(params *page.PrintToPDFParams, w io.Writer) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, stream, err := params.WithTransferMode(page.PrintToPDFTransferModeReturnAsStream).Do(ctx)
+		if err != nil {
+			return err
+		}
+		defer cdpio.Close(stream).Do(ctx)
+
+		for {
+			var res cdpio.ReadReturns
+			if err := cdp.Execute(ctx, cdpio.CommandRead, cdpio.Read(stream).WithSize(streamChunkSize), &res); err != nil {
+				return fmt.Errorf("failed to read PDF stream: %w", err)
+			}
+
+			chunk := []byte(res.Data)
+			if res.Base64encoded {
+				chunk, err = base64.StdEncoding.DecodeString(res.Data)
+				if err != nil {
+					return fmt.Errorf("failed to decode PDF stream: %w", err)
+				}
+			}
+
+			if len(chunk) > 0 {
+				if _, err := w.Write(chunk); err != nil {
+					return fmt.Errorf("failed to write PDF: %w", err)
+				}
+			}
+
+			if res.EOF {
+				return nil
+			}
+		}
+	})
+}
+
+var mediaBoxPattern = regexp.MustCompile(`/MediaBox\s*\[\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s*\]`)
+
+// mediaBoxSniffer passes data through and records the first page MediaBox
+// it sees, in inches. A short tail is kept so a match split across two
+// chunks is still found.
+type mediaBoxSniffer struct {
+	w             io.Writer
+	tail          []byte
+	found         bool
+	width, height float64
+}
+
+func (m *mediaBoxSniffer) Write(p []byte) (int, error) {
+	if !m.found {
+		buf := append(m.tail, p...)
+		if match := mediaBoxPattern.FindSubmatch(buf); match != nil {
+			var box [4]float64
+			for i := range box {
+				box[i], _ = strconv.ParseFloat(string(match[i+1]), 64)
+			}
+			m.width = math.Abs(box[2]-box[0]) / 72
+			m.height = math.Abs(box[3]-box[1]) / 72
+			m.found = true
+			m.tail = nil
+		} else {
+			keep := min(len(buf), 128)
+			m.tail = append(m.tail[:0], buf[len(buf)-keep:]...)
+		}
+	}
+	return m.w.Write(p)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,149 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidScreenshot = errors.New("invalid screenshot options")
+
+// OutputFormat is what a render produces: a PDF or a raster screenshot.
+type OutputFormat string
+
+const (
+	OutputPDF  OutputFormat = "pdf"
+	OutputPNG  OutputFormat = "png"
+	OutputJPEG OutputFormat = "jpeg"
+	OutputWebP OutputFormat = "webp"
+)
+
+func (f OutputFormat) ContentType() string {
+	switch f {
+	case OutputPNG:
+		return "image/png"
+	case OutputJPEG:
+		return "image/jpeg"
+	case OutputWebP:
+		return "image/webp"
+	default:
+		return "application/pdf"
+	}
+}
+
+func (f OutputFormat) Extension() string {
+	switch f {
+	case OutputPNG:
+		return ".png"
+	case OutputJPEG:
+		return ".jpg"
+	case OutputWebP:
+		return ".webp"
+	default:
+		return ".pdf"
+	}
+}
+
+const (
+	DefaultViewportWidth  = 1280
+	DefaultViewportHeight = 800
+)
+
+// Clip is a region of the page in CSS pixels.
+type Clip struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// ScreenshotOptions turns a render into a raster capture of the page
+// instead of a PDF. Print-only fields such as margins, header and footer
+// are ignored.
+type ScreenshotOptions struct {
+	Format            OutputFormat // png, jpeg or webp
+	Quality           int          // 1-100, jpeg and webp only
+	FullPage          bool         // capture the whole document, not just the viewport
+	Clip              *Clip        // capture only this region, overrides FullPage
+	ViewportWidth     int
+	ViewportHeight    int
+	DeviceScaleFactor float64
+}
+
+func (s *ScreenshotOptions) Validate() error {
+	switch s.Format {
+	case OutputPNG, OutputJPEG, OutputWebP:
+	default:
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidScreenshot, s.Format)
+	}
+	if s.Quality < 0 || s.Quality > 100 {
+		return fmt.Errorf("%w: quality must be between 0 and 100", ErrInvalidScreenshot)
+	}
+	if s.Quality > 0 && s.Format == OutputPNG {
+		return fmt.Errorf("%w: quality is not supported for png", ErrInvalidScreenshot)
+	}
+	if s.Clip != nil && (s.Clip.Width <= 0 || s.Clip.Height <= 0) {
+		return fmt.Errorf("%w: clip width and height must be positive", ErrInvalidScreenshot)
+	}
+	if s.ViewportWidth < 0 || s.ViewportHeight < 0 || s.DeviceScaleFactor < 0 {
+		return fmt.Errorf("%w: viewport and scale factor must not be negative", ErrInvalidScreenshot)
+	}
+	return nil
+}
+
+// setViewport must run before the document loads so layout happens at the
+// requested width.
+func (s *ScreenshotOptions) setViewport() chromedp.Action {
+	width, height := s.ViewportWidth, s.ViewportHeight
+	if width == 0 {
+		width = DefaultViewportWidth
+	}
+	if height == 0 {
+		height = DefaultViewportHeight
+	}
+	scale := s.DeviceScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+	return emulation.SetDeviceMetricsOverride(int64(width), int64(height), scale, false)
+}
+
+func (s *ScreenshotOptions) captureToWriter(w io.Writer) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		params := page.CaptureScreenshot().
+			WithFormat(page.CaptureScreenshotFormat(s.Format)).
+			WithFromSurface(true)
+		if s.Quality > 0 {
+			params = params.WithQuality(int64(s.Quality))
+		}
+
+		switch {
+		case s.Clip != nil:
+			params = params.
+				WithCaptureBeyondViewport(true).
+				WithClip(&page.Viewport{X: s.Clip.X, Y: s.Clip.Y, Width: s.Clip.Width, Height: s.Clip.Height, Scale: 1})
+		case s.FullPage:
+			_, _, _, _, _, contentSize, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			params = params.
+				WithCaptureBeyondViewport(true).
+				WithClip(&page.Viewport{Width: contentSize.Width, Height: contentSize.Height, Scale: 1})
+		}
+
+		data, err := params.Do(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write screenshot: %w", err)
+		}
+		return nil
+	})
+}
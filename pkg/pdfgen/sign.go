@@ -0,0 +1,551 @@
+package pdfgen
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pkcs7"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	ErrInvalidSigner        = errors.New("invalid signing certificate")
+	ErrSigningNotConfigured = errors.New("signing is not configured")
+	ErrInvalidSignature     = errors.New("invalid signature options")
+)
+
+// Signer holds the certificate and private key PDFs are signed with.
+type Signer struct {
+	cert  *x509.Certificate
+	chain []*x509.Certificate // issuers of cert, nearest first
+	key   crypto.Signer
+}
+
+// LoadSigner reads a signing certificate and its private key. A .p12 or
+// .pfx certPath is a PKCS#12 bundle opened with password. Anything else is
+// PEM: the certificate followed by any intermediates, with the unencrypted
+// key in keyPath or, if keyPath is empty, in the same file.
+func LoadSigner(certPath, keyPath, password string) (*Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigner, err)
+	}
+
+	var (
+		key   any
+		certs []*x509.Certificate
+	)
+	switch strings.ToLower(filepath.Ext(certPath)) {
+	case ".p12", ".pfx":
+		var cert *x509.Certificate
+		var cas []*x509.Certificate
+		if key, cert, cas, err = pkcs12.DecodeChain(data, password); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSigner, err)
+		}
+		certs = append([]*x509.Certificate{cert}, cas...)
+	default:
+		if certs, err = parsePEMCertificates(data); err != nil {
+			return nil, err
+		}
+		if keyPath != "" {
+			if data, err = os.ReadFile(keyPath); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSigner, err)
+			}
+		}
+		if key, err = parsePEMKey(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return newSigner(key, certs)
+}
+
+func newSigner(key any, certs []*x509.Certificate) (*Signer, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %T, expected RSA or ECDSA", ErrInvalidSigner, key)
+	}
+	signer := key.(crypto.Signer)
+
+	cert := certs[0]
+	if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(cert.PublicKey) {
+		return nil, fmt.Errorf("%w: private key does not match certificate", ErrInvalidSigner)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("%w: certificate expired on %s", ErrInvalidSigner, cert.NotAfter.Format(time.DateOnly))
+	}
+
+	return &Signer{cert: cert, chain: issuerChain(cert, certs[1:]), key: signer}, nil
+}
+
+// issuerChain orders the certificates that issued cert, nearest first,
+// and drops any that aren't part of its chain.
+func issuerChain(cert *x509.Certificate, pool []*x509.Certificate) []*x509.Certificate {
+	var chain []*x509.Certificate
+	for current := cert; !bytes.Equal(current.RawIssuer, current.RawSubject); {
+		var issuer *x509.Certificate
+		for _, c := range pool {
+			if bytes.Equal(c.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(c) == nil {
+				issuer = c
+				break
+			}
+		}
+		if issuer == nil || len(chain) == len(pool) {
+			break
+		}
+		chain = append(chain, issuer)
+		current = issuer
+	}
+	return chain
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSigner, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no PEM certificate found", ErrInvalidSigner)
+	}
+	return certs, nil
+}
+
+func parsePEMKey(data []byte) (any, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "ENCRYPTED PRIVATE KEY" || strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+			return nil, fmt.Errorf("%w: encrypted PEM keys are not supported, use a PKCS#12 file", ErrInvalidSigner)
+		}
+
+		var (
+			key any
+			err error
+		)
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSigner, err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: no PEM private key found", ErrInvalidSigner)
+}
+
+// Name is the signer's common name, or its full subject without one.
+func (s *Signer) Name() string {
+	if s.cert.Subject.CommonName != "" {
+		return s.cert.Subject.CommonName
+	}
+	return s.cert.Subject.String()
+}
+
+// Root returns the top of the signer's chain: the root CA when the chain
+// given to LoadSigner was complete.
+func (s *Signer) Root() *x509.Certificate {
+	if len(s.chain) == 0 {
+		return s.cert
+	}
+	return s.chain[len(s.chain)-1]
+}
+
+// Signature requests a PAdES signature over PDF output. It is applied
+// last, so nothing changes the document after signing.
+type Signature struct {
+	Reason      string
+	Location    string
+	ContactInfo string
+
+	// Appearance, when set, draws the signature on a page. Without it the
+	// signature is invisible.
+	Appearance *SignatureAppearance
+}
+
+// SignatureAppearance places a visible signature. X and Y are the offset
+// of its top-left corner from the top-left of the page, in points.
+type SignatureAppearance struct {
+	Page          int // 1-based; 0 means the last page
+	X, Y          float64
+	Width, Height float64
+}
+
+func (s *Signature) Validate() error {
+	if a := s.Appearance; a != nil {
+		if a.Page < 0 {
+			return fmt.Errorf("%w: appearance page must not be negative", ErrInvalidSignature)
+		}
+		if a.X < 0 || a.Y < 0 {
+			return fmt.Errorf("%w: appearance position must not be negative", ErrInvalidSignature)
+		}
+		if a.Width < 20 || a.Height < 10 {
+			return fmt.Errorf("%w: appearance must be at least 20pt wide and 10pt high", ErrInvalidSignature)
+		}
+	}
+	return nil
+}
+
+// Placeholder sizes, reserved in the signature dictionary before the
+// offsets they describe are known.
+const (
+	byteRangePlaceholder = 9999999999
+	signatureReserve     = 8192
+)
+
+var oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+// signPDF appends a signature to pdf as an incremental update. The CMS
+// signature is detached and carries the signing-certificate-v2 attribute
+// PAdES requires, with the ETSI.CAdES.detached sub-filter.
+func signPDF(pdf []byte, s *Signer, sig *Signature) ([]byte, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(pdf), pdfConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	now := time.Now()
+	reserve := signatureReserve
+	for _, c := range append([]*x509.Certificate{s.cert}, s.chain...) {
+		reserve += len(c.Raw)
+	}
+
+	sigDict := types.Dict{
+		"Type":      types.Name("Sig"),
+		"Filter":    types.Name("Adobe.PPKLite"),
+		"SubFilter": types.Name("ETSI.CAdES.detached"),
+		"ByteRange": types.Array{types.Integer(0), types.Integer(byteRangePlaceholder), types.Integer(byteRangePlaceholder), types.Integer(byteRangePlaceholder)},
+		"Contents":  types.HexLiteral(strings.Repeat("0", 2*reserve)),
+		"M":         types.StringLiteral(types.DateString(now)),
+	}
+	for key, value := range map[string]string{
+		"Name":        s.Name(),
+		"Reason":      sig.Reason,
+		"Location":    sig.Location,
+		"ContactInfo": sig.ContactInfo,
+	} {
+		if value == "" {
+			continue
+		}
+		escaped, err := types.EscapedUTF16String(value)
+		if err != nil {
+			return nil, err
+		}
+		sigDict[key] = types.StringLiteral(*escaped)
+	}
+	sigRef, err := ctx.IndRefForNewObject(sigDict)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Write.IncrementWithObjNr(sigRef.ObjectNumber.Value())
+
+	pageNr := ctx.PageCount
+	if sig.Appearance != nil && sig.Appearance.Page != 0 {
+		pageNr = sig.Appearance.Page
+	}
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		return nil, fmt.Errorf("%w: page %d is outside the document's %d pages", ErrInvalidSignature, pageNr, ctx.PageCount)
+	}
+	page, pageRef, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	fieldName, err := signatureFieldName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	field := types.Dict{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"FT":      types.Name("Sig"),
+		"T":       types.StringLiteral(fieldName),
+		"V":       *sigRef,
+		"F":       types.Integer(132), // Print, Locked
+		"P":       *pageRef,
+		"Rect":    types.NewNumberArray(0, 0, 0, 0),
+	}
+	if sig.Appearance != nil {
+		rect, ap, err := signatureAppearance(ctx, sig, s, inherited, now)
+		if err != nil {
+			return nil, err
+		}
+		field["Rect"] = rect.Array()
+		field["AP"] = types.Dict{"N": *ap}
+	}
+	fieldRef, err := ctx.IndRefForNewObject(field)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Write.IncrementWithObjNr(fieldRef.ObjectNumber.Value())
+
+	if err := appendToArray(ctx, page, "Annots", *fieldRef, pageRef.ObjectNumber.Value()); err != nil {
+		return nil, err
+	}
+	if err := addSignatureField(ctx, *fieldRef); err != nil {
+		return nil, err
+	}
+
+	out, err := appendIncrement(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+	return fillSignature(out, len(pdf), reserve, s)
+}
+
+// signatureFieldName picks the first free name of the form Signature<n>.
+func signatureFieldName(ctx *model.Context) (string, error) {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	taken := map[string]bool{}
+	if form, _ := ctx.DereferenceDict(catalog["AcroForm"]); form != nil {
+		fields, _ := ctx.DereferenceArray(form["Fields"])
+		for _, f := range fields {
+			d, err := ctx.DereferenceDict(f)
+			if err != nil || d == nil {
+				continue
+			}
+			if t := d.StringLiteralEntry("T"); t != nil {
+				name, _ := types.StringLiteralToString(*t)
+				taken[name] = true
+			}
+		}
+	}
+	for n := 1; ; n++ {
+		if name := fmt.Sprintf("Signature%d", n); !taken[name] {
+			return name, nil
+		}
+	}
+}
+
+// appendToArray adds item to the array d[key], creating it if needed, and
+// queues whichever object holds the array for the increment. objNr is the
+// object d belongs to.
+func appendToArray(ctx *model.Context, d types.Dict, key string, item types.Object, objNr int) error {
+	if ref, ok := d[key].(types.IndirectRef); ok {
+		arr, err := ctx.DereferenceArray(ref)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+		}
+		entry, _ := ctx.FindTableEntryForIndRef(&ref)
+		if entry == nil {
+			return fmt.Errorf("%w: missing %s array", ErrInvalidPDF, key)
+		}
+		entry.Object = append(arr, item)
+		ctx.Write.IncrementWithObjNr(ref.ObjectNumber.Value())
+		return nil
+	}
+
+	arr, _ := d[key].(types.Array)
+	d[key] = append(arr, item)
+	ctx.Write.IncrementWithObjNr(objNr)
+	return nil
+}
+
+// addSignatureField registers field in the document's AcroForm, creating
+// the form if there is none, and marks the document as signed.
+func addSignatureField(ctx *model.Context, field types.IndirectRef) error {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	formObjNr := ctx.Root.ObjectNumber.Value()
+	var form types.Dict
+	switch obj := catalog["AcroForm"].(type) {
+	case types.IndirectRef:
+		if form, err = ctx.DereferenceDict(obj); err != nil || form == nil {
+			return fmt.Errorf("%w: invalid AcroForm", ErrInvalidPDF)
+		}
+		formObjNr = obj.ObjectNumber.Value()
+	case types.Dict:
+		form = obj
+	default:
+		form = types.NewDict()
+		catalog["AcroForm"] = form
+	}
+
+	form["SigFlags"] = types.Integer(3) // SignaturesExist, AppendOnly
+	return appendToArray(ctx, form, "Fields", field, formObjNr)
+}
+
+// signatureAppearance builds the visible signature's rectangle on the page
+// and the form XObject drawn in it.
+func signatureAppearance(ctx *model.Context, sig *Signature, s *Signer, page *model.InheritedPageAttrs, now time.Time) (*types.Rectangle, *types.IndirectRef, error) {
+	box := page.CropBox
+	if box == nil {
+		box = page.MediaBox
+	}
+	if box == nil {
+		return nil, nil, fmt.Errorf("%w: page has no MediaBox", ErrInvalidPDF)
+	}
+
+	a := sig.Appearance
+	llx := box.LL.X + a.X
+	ury := box.UR.Y - a.Y
+	rect := types.NewRectangle(llx, ury-a.Height, llx+a.Width, ury)
+	if rect.LL.Y < box.LL.Y || rect.UR.X > box.UR.X {
+		return nil, nil, fmt.Errorf("%w: appearance does not fit on the page", ErrInvalidSignature)
+	}
+
+	lines := []string{
+		"Digitally signed by " + s.Name(),
+		"Date: " + now.Format("2006-01-02 15:04:05 -07:00"),
+	}
+	if sig.Reason != "" {
+		lines = append(lines, "Reason: "+sig.Reason)
+	}
+	if sig.Location != "" {
+		lines = append(lines, "Location: "+sig.Location)
+	}
+
+	const padding = 3
+	fontSize := min(10, (a.Height-2*padding)/(float64(len(lines))*1.2))
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "q 0.5 G 0.5 w 0.25 0.25 %.2f %.2f re S\n", a.Width-0.5, a.Height-0.5)
+	fmt.Fprintf(&content, "0 0 %.2f %.2f re W n\n", a.Width, a.Height)
+	fmt.Fprintf(&content, "BT 0 g /Helv %.2f Tf %.2f TL %d %.2f Td\n", fontSize, fontSize*1.2, padding, a.Height-padding-fontSize)
+	for i, line := range lines {
+		if i > 0 {
+			content.WriteString("T* ")
+		}
+		fmt.Fprintf(&content, "(%s) Tj\n", pdfTextString(line))
+	}
+	content.WriteString("ET Q\n")
+
+	font := types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("Type1"),
+		"BaseFont": types.Name("Helvetica"),
+		"Encoding": types.Name("WinAnsiEncoding"),
+	}
+	xobject := types.StreamDict{Dict: types.NewDict(), Content: content.Bytes()}
+	xobject.InsertName("Type", "XObject")
+	xobject.InsertName("Subtype", "Form")
+	xobject.Insert("BBox", types.NewNumberArray(0, 0, a.Width, a.Height))
+	xobject.Insert("Resources", types.Dict{"Font": types.Dict{"Helv": font}})
+	if err := xobject.Encode(); err != nil {
+		return nil, nil, err
+	}
+	ref, err := ctx.IndRefForNewObject(xobject)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx.Write.IncrementWithObjNr(ref.ObjectNumber.Value())
+	return rect, ref, nil
+}
+
+// pdfTextString escapes s for a literal string shown with a WinAnsi font.
+// Characters outside Latin-1 become "?".
+func pdfTextString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "\\%03o", r)
+		}
+	}
+	return b.String()
+}
+
+// fillSignature fills in the ByteRange and Contents placeholders of the
+// signature dictionary written after offset.
+func fillSignature(pdf []byte, offset, reserve int, s *Signer) ([]byte, error) {
+	increment := pdf[offset:]
+
+	contentsAt := bytes.Index(increment, []byte("<"+strings.Repeat("0", 2*reserve)+">"))
+	rangeAt := bytes.Index(increment, []byte("/ByteRange"))
+	if contentsAt < 0 || rangeAt < 0 {
+		return nil, errors.New("failed to sign PDF: signature placeholder not found")
+	}
+	rangeStart := offset + rangeAt + bytes.IndexByte(increment[rangeAt:], '[')
+	rangeEnd := offset + rangeAt + bytes.IndexByte(increment[rangeAt:], ']') + 1
+
+	contentsStart := offset + contentsAt
+	contentsEnd := contentsStart + 2*reserve + 2
+
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contentsStart, contentsEnd, len(pdf)-contentsEnd)
+	if len(byteRange) > rangeEnd-rangeStart {
+		return nil, errors.New("failed to sign PDF: byte range does not fit")
+	}
+	copy(pdf[rangeStart:rangeEnd], byteRange+strings.Repeat(" ", rangeEnd-rangeStart-len(byteRange)))
+
+	signed := make([]byte, 0, len(pdf)-(contentsEnd-contentsStart))
+	signed = append(signed, pdf[:contentsStart]...)
+	signed = append(signed, pdf[contentsEnd:]...)
+
+	cms, err := s.sign(signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign PDF: %w", err)
+	}
+	if len(cms) > reserve {
+		return nil, errors.New("failed to sign PDF: signature does not fit its placeholder")
+	}
+	hex.Encode(pdf[contentsStart+1:], cms)
+	return pdf, nil
+}
+
+// sign returns the detached CMS signature of data.
+func (s *Signer) sign(data []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+
+	certHash := sha256.Sum256(s.cert.Raw)
+	signingCert := struct {
+		Certs []struct{ CertHash []byte }
+	}{Certs: []struct{ CertHash []byte }{{CertHash: certHash[:]}}}
+
+	config := pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{{Type: oidSigningCertificateV2, Value: signingCert}},
+	}
+	if err := sd.AddSignerChain(s.cert, s.key, s.chain, config); err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	return sd.Finish()
+}
@@ -0,0 +1,179 @@
+package pdfgen
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testPDF builds a small document with the given number of blank pages.
+func testPDF(t *testing.T, pages int) []byte {
+	t.Helper()
+
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>"}
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 3+i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages))
+	for range pages {
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << >> >>")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// testSigner returns a signer with a throwaway self-signed certificate and
+// a pool trusting it.
+func testSigner(t *testing.T) (*Signer, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pdfgen test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := newSigner(key, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return signer, roots
+}
+
+func TestSignPDFRoundTrip(t *testing.T) {
+	signer, roots := testSigner(t)
+	pdf := testPDF(t, 2)
+
+	tests := []struct {
+		name string
+		sig  *Signature
+	}{
+		{"invisible", &Signature{Reason: "Approved", Location: "Lahore"}},
+		{"visible", &Signature{Reason: "Approved", Appearance: &SignatureAppearance{Page: 2, X: 40, Y: 40, Width: 200, Height: 60}}},
+		{"visible on the last page", &Signature{Appearance: &SignatureAppearance{X: 0, Y: 0, Width: 20, Height: 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signPDF(pdf, signer, tt.sig)
+			if err != nil {
+				t.Fatalf("signPDF: %v", err)
+			}
+			if !bytes.HasPrefix(signed, pdf) {
+				t.Error("signing rewrote the original revision instead of appending to it")
+			}
+
+			infos, err := VerifySignatures(bytes.NewReader(signed), VerifyOptions{Roots: roots})
+			if err != nil {
+				t.Fatalf("VerifySignatures: %v", err)
+			}
+			if len(infos) != 1 {
+				t.Fatalf("found %d signatures, want 1", len(infos))
+			}
+			info := infos[0]
+			if info.Status != SignatureValid || info.Modified || !info.Trusted {
+				t.Errorf("signature status %s (%s), modified %v, trusted %v; problems: %v", info.Status, info.Detail, info.Modified, info.Trusted, info.Problems)
+			}
+			if info.Reason != tt.sig.Reason {
+				t.Errorf("reason = %q, want %q", info.Reason, tt.sig.Reason)
+			}
+			if visible := tt.sig.Appearance != nil; info.Visible != visible {
+				t.Errorf("visible = %v, want %v", info.Visible, visible)
+			}
+		})
+	}
+}
+
+func TestSignPDFTwice(t *testing.T) {
+	signer, roots := testSigner(t)
+
+	first, err := signPDF(testPDF(t, 1), signer, &Signature{Reason: "First"})
+	if err != nil {
+		t.Fatalf("first signPDF: %v", err)
+	}
+	second, err := signPDF(first, signer, &Signature{Reason: "Second"})
+	if err != nil {
+		t.Fatalf("second signPDF: %v", err)
+	}
+	if !bytes.HasPrefix(second, first) {
+		t.Fatal("the second signature rewrote the first revision")
+	}
+
+	infos, err := VerifySignatures(bytes.NewReader(second), VerifyOptions{Roots: roots})
+	if err != nil {
+		t.Fatalf("VerifySignatures: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("found %d signatures, want 2", len(infos))
+	}
+	reasons := map[string]bool{}
+	for _, info := range infos {
+		reasons[info.Reason] = true
+		if info.Status != SignatureValid || info.Modified {
+			t.Errorf("%s signature status %s (%s), modified %v; problems: %v", info.Reason, info.Status, info.Detail, info.Modified, info.Problems)
+		}
+	}
+	if !reasons["First"] || !reasons["Second"] {
+		t.Errorf("signatures found: %v, want First and Second", reasons)
+	}
+}
+
+func TestSignPDFTampered(t *testing.T) {
+	signer, roots := testSigner(t)
+
+	signed, err := signPDF(testPDF(t, 1), signer, &Signature{Reason: "Approved"})
+	if err != nil {
+		t.Fatalf("signPDF: %v", err)
+	}
+	i := bytes.Index(signed, []byte("595 842"))
+	if i < 0 {
+		t.Fatal("media box not found")
+	}
+	tampered := bytes.Clone(signed)
+	copy(tampered[i:], "612 792")
+
+	infos, err := VerifySignatures(bytes.NewReader(tampered), VerifyOptions{Roots: roots})
+	if err != nil {
+		t.Fatalf("VerifySignatures: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Status == SignatureValid {
+		t.Errorf("tampered document verified: %+v", infos)
+	}
+}
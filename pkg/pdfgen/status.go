@@ -0,0 +1,54 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/chromedp/chromedp"
+)
+
+var ErrInvalidStatusCode = errors.New("invalid status code")
+
+// HTTPStatusError is returned when the page a URL render navigated to
+// answered with a 4xx or 5xx status that was not explicitly accepted.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func validateStatusCodes(codes []int) error {
+	for _, code := range codes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("%w: %d", ErrInvalidStatusCode, code)
+		}
+	}
+	return nil
+}
+
+// navigateChecked loads targetURL and stores the status of the main
+// document, after redirects, in *status. Error statuses fail the load
+// unless listed in accept.
+func navigateChecked(targetURL string, accept []int, status *int) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		resp, err := chromedp.RunResponse(ctx, chromedp.Navigate(targetURL))
+		if err != nil {
+			return err
+		}
+		if resp == nil {
+			return nil
+		}
+
+		*status = int(resp.Status)
+		if *status >= 400 && !slices.Contains(accept, *status) {
+			return &HTTPStatusError{URL: resp.URL, StatusCode: *status}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,399 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+var (
+	ErrTemplateTooLarge = errors.New("template output is too large")
+	ErrTemplateTimeout  = errors.New("template execution took too long")
+)
+
+const (
+	mainTemplateName  = "main"
+	maxTemplateOutput = 20 << 20
+
+	// TemplateTimeout bounds execution when the caller's context has no
+	// deadline of its own.
+	TemplateTimeout = 10 * time.Second
+
+	checkpointFunc = "_pdfgen_checkpoint"
+)
+
+// Template is an html/template document plus the partials and layouts it
+// uses. Partials are referenced by name with {{template "name" .}}; a
+// layout can declare {{block "content" .}} for Text to fill in with
+// {{define "content"}}.
+type Template struct {
+	Text     string
+	Partials map[string]string
+}
+
+// TemplateError points at the template and position where parsing or
+// execution failed. Column is 0 when Go only reports the line, which is the
+// case for most parse errors.
+type TemplateError struct {
+	Template string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (e *TemplateError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Template, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Template, e.Line, e.Message)
+}
+
+var templateErrorPattern = regexp.MustCompile(`^(?:html/)?template: ?([^:]+):(\d+)(?::(\d+))?: (.*)$`)
+
+// templateError turns the text/template and html/template error formats
+// into a TemplateError. Errors without a position are returned as is.
+func templateError(err error) error {
+	var tooLarge *limitError
+	if errors.As(err, &tooLarge) {
+		return ErrTemplateTooLarge
+	}
+	for _, sentinel := range []error{ErrTemplateTimeout, context.Canceled} {
+		if errors.Is(err, sentinel) {
+			return sentinel
+		}
+	}
+	m := templateErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(m[2])
+	column, _ := strconv.Atoi(m[3])
+	return &TemplateError{Template: m[1], Line: line, Column: column, Message: m[4]}
+}
+
+// Validate parses t without executing it.
+func (t *Template) Validate() error {
+	_, err := t.parse(context.Background())
+	return err
+}
+
+// parse also plants checkpoints that abort execution once ctx is done.
+func (t *Template) parse(ctx context.Context) (*template.Template, error) {
+	tmpl := template.New(mainTemplateName).Funcs(TemplateFuncs()).Funcs(template.FuncMap{
+		checkpointFunc: func() (string, error) {
+			if err := ctx.Err(); err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					return "", ErrTemplateTimeout
+				}
+				return "", err
+			}
+			return "", nil
+		},
+	})
+
+	names := make([]string, 0, len(t.Partials))
+	for name := range t.Partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := tmpl.New(name).Parse(t.Partials[name]); err != nil {
+			return nil, templateError(err)
+		}
+	}
+
+	// Parsed last so its {{define}}s override blocks declared by layouts.
+	if _, err := tmpl.Parse(t.Text); err != nil {
+		return nil, templateError(err)
+	}
+	for _, named := range tmpl.Templates() {
+		if named.Tree != nil {
+			addCheckpoints(named.Tree.Root, true)
+		}
+	}
+	return tmpl, nil
+}
+
+// addCheckpoints puts a checkpoint at the start of list, if top is set,
+// and of every range body in it. Those are the only places a template can
+// loop, directly or by calling itself. Checkpoints are variable
+// declarations, which print nothing and which html/template leaves alone.
+func addCheckpoints(list *parse.ListNode, top bool) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			addCheckpoints(n.List, false)
+			addCheckpoints(n.ElseList, false)
+		case *parse.WithNode:
+			addCheckpoints(n.List, false)
+			addCheckpoints(n.ElseList, false)
+		case *parse.RangeNode:
+			addCheckpoints(n.List, true)
+			addCheckpoints(n.ElseList, false)
+		}
+	}
+	if top {
+		list.Nodes = append([]parse.Node{checkpointNode()}, list.Nodes...)
+	}
+}
+
+func checkpointNode() *parse.ActionNode {
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Decl:     []*parse.VariableNode{{NodeType: parse.NodeVariable, Ident: []string{"$" + checkpointFunc}}},
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Args:     []parse.Node{&parse.IdentifierNode{NodeType: parse.NodeIdentifier, Ident: checkpointFunc}},
+			}},
+		},
+	}
+}
+
+// ExecuteTemplate renders t with data into HTML. Execution stops with
+// ErrTemplateTimeout after TemplateTimeout, or at ctx's own deadline.
+func ExecuteTemplate(ctx context.Context, t *Template, data any) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, TemplateTimeout)
+		defer cancel()
+	}
+
+	tmpl, err := t.parse(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&limitWriter{w: &buf, n: maxTemplateOutput}, mainTemplateName, data); err != nil {
+		return "", templateError(err)
+	}
+	return buf.String(), nil
+}
+
+// FromTemplateContext executes t with data and renders the resulting HTML
+// to outputPath.
+func (g *Generator) FromTemplateContext(ctx context.Context, t *Template, data any, outputPath string, opts *PrintOptions) error {
+	html, err := ExecuteTemplate(ctx, t, data)
+	if err != nil {
+		return err
+	}
+	return g.FromHTMLWithCustomOptionsContext(ctx, html, outputPath, opts)
+}
+
+type limitError struct{}
+
+func (*limitError) Error() string { return ErrTemplateTooLarge.Error() }
+
+// limitWriter stops runaway templates, such as a range over a huge number,
+// before they exhaust memory.
+type limitWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, &limitError{}
+	}
+	l.n -= int64(len(p))
+	return l.w.Write(p)
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹",
+	"PKR": "Rs ", "AUD": "A$", "CAD": "C$", "CHF": "CHF ", "AED": "AED ", "SAR": "SAR ",
+}
+
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true}
+
+var datePresets = map[string]string{
+	"short":    "02 Jan 2006",
+	"long":     "January 2, 2006",
+	"iso":      "2006-01-02",
+	"datetime": "02 Jan 2006 15:04",
+	"rfc3339":  time.RFC3339,
+}
+
+// TemplateFuncs are the helpers available to every template:
+//
+//	{{currency 1234.5 "EUR"}}           €1,234.50
+//	{{number 1234567.891 2}}            1,234,567.89
+//	{{percent 0.256 1}}                 25.6%
+//	{{date "long" .CreatedAt}}          March 4, 2025
+//	{{plural .Count "item" "items"}}    items
+//	{{default "n/a" .Missing}}          n/a
+//
+// The helpers take numbers as float64, so values beyond about 15
+// significant digits are rounded; only a number printed as-is keeps its
+// exact JSON text.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"currency": formatCurrency,
+		"number":   formatNumber,
+		"percent":  formatPercent,
+		"date":     formatDate,
+		"now":      time.Now,
+		"plural":   plural,
+		"default":  defaultValue,
+	}
+}
+
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%v (%T) is not a number", v, v)
+}
+
+func groupThousands(value float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if value < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteByte('.')
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+func formatNumber(value any, decimals ...int) (string, error) {
+	f, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	d := 0
+	if len(decimals) > 0 {
+		d = decimals[0]
+	}
+	return groupThousands(f, d), nil
+}
+
+func formatPercent(value any, decimals ...int) (string, error) {
+	f, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	d := 0
+	if len(decimals) > 0 {
+		d = decimals[0]
+	}
+	return groupThousands(f*100, d) + "%", nil
+}
+
+func formatCurrency(value any, code string) (string, error) {
+	f, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	code = strings.ToUpper(code)
+	decimals := 2
+	if zeroDecimalCurrencies[code] {
+		decimals = 0
+	}
+
+	amount := groupThousands(math.Abs(f), decimals)
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		symbol = code + " "
+	}
+	if f < 0 && amount != groupThousands(0, decimals) {
+		return "-" + symbol + amount, nil
+	}
+	return symbol + amount, nil
+}
+
+// formatDate accepts a time.Time, an RFC 3339 or YYYY-MM-DD string, or Unix
+// seconds. layout is a preset name or a Go time layout.
+func formatDate(layout string, value any) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339, v); err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return "", fmt.Errorf("cannot parse date %q", v)
+			}
+		}
+	default:
+		seconds, err := toFloat(v)
+		if err != nil {
+			return "", err
+		}
+		t = time.Unix(int64(seconds), 0).UTC()
+	}
+
+	if preset, ok := datePresets[layout]; ok {
+		layout = preset
+	}
+	return t.Format(layout), nil
+}
+
+func plural(count any, singular, pluralForm string) (string, error) {
+	n, err := toFloat(count)
+	if err != nil {
+		return "", err
+	}
+	if math.Abs(n) == 1 {
+		return singular, nil
+	}
+	return pluralForm, nil
+}
+
+func defaultValue(fallback, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return fallback
+	case string:
+		if v == "" {
+			return fallback
+		}
+	}
+	return value
+}
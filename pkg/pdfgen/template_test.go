@@ -0,0 +1,218 @@
+package pdfgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+func TestGroupThousands(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		want     string
+	}{
+		{0, 0, "0"},
+		{999, 0, "999"},
+		{1000, 0, "1,000"},
+		{1234567.891, 2, "1,234,567.89"},
+		{-1234.5, 1, "-1,234.5"},
+		{0.5, 0, "0"},
+		{999.999, 2, "1,000.00"},
+		{-0.001, 2, "0.00"},
+		{math.Copysign(0, -1), 2, "0.00"},
+		{-1e9, 0, "-1,000,000,000"},
+	}
+	for _, tt := range tests {
+		if got := groupThousands(tt.value, tt.decimals); got != tt.want {
+			t.Errorf("groupThousands(%v, %d) = %q, want %q", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	tests := []struct {
+		value   any
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{1234.5, "EUR", "€1,234.50", false},
+		{1234.5, "usd", "$1,234.50", false},
+		{-1234.5, "USD", "-$1,234.50", false},
+		{1234.6, "JPY", "¥1,235", false},
+		{1234567, "KRW", "KRW 1,234,567", false},
+		{-0.4, "JPY", "¥0", false},
+		{-0.001, "USD", "$0.00", false},
+		{math.Copysign(0, -1), "EUR", "€0.00", false},
+		{json.Number("12345678901.25"), "GBP", "£12,345,678,901.25", false},
+		{"99.9", "XYZ", "XYZ 99.90", false},
+		{nil, "USD", "$0.00", false},
+		{"abc", "USD", "", true},
+		{true, "USD", "", true},
+	}
+	for _, tt := range tests {
+		got, err := formatCurrency(tt.value, tt.code)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("formatCurrency(%v, %q) error = %v, want error %v", tt.value, tt.code, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("formatCurrency(%v, %q) = %q, want %q", tt.value, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	moment := time.Date(2025, time.March, 4, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		layout  string
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{"long", moment, "March 4, 2025", false},
+		{"short", "2025-03-04", "04 Mar 2025", false},
+		{"iso", "2025-03-04T15:30:00Z", "2025-03-04", false},
+		{"datetime", "2025-03-04T15:30:00+02:00", "04 Mar 2025 15:30", false},
+		{"rfc3339", moment.Unix(), "2025-03-04T15:30:00Z", false},
+		{"2006", json.Number("1741102200"), "2025", false},
+		{"Jan 2", 0, "Jan 1", false},
+		{"long", "04/03/2025", "", true},
+		{"long", []int{1}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := formatDate(tt.layout, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("formatDate(%q, %v) error = %v, want error %v", tt.layout, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("formatDate(%q, %v) = %q, want %q", tt.layout, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		count   any
+		want    string
+		wantErr bool
+	}{
+		{0, "items", false},
+		{1, "item", false},
+		{-1, "item", false},
+		{2, "items", false},
+		{1.0, "item", false},
+		{1.5, "items", false},
+		{0.5, "items", false},
+		{-1.0, "item", false},
+		{json.Number("1"), "item", false},
+		{json.Number("1.0"), "item", false},
+		{json.Number("1.5"), "items", false},
+		{json.Number("2"), "items", false},
+		{"1", "item", false},
+		{"3", "items", false},
+		{nil, "items", false},
+		{"many", "", true},
+	}
+	for _, tt := range tests {
+		got, err := plural(tt.count, "item", "items")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("plural(%v) error = %v, want error %v", tt.count, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("plural(%v) = %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateError(t *testing.T) {
+	textParse := func(text string) error {
+		_, err := texttemplate.New("main").Parse(text)
+		return err
+	}
+	htmlParse := func(text string) error {
+		_, err := htmltemplate.New("main").Parse(text)
+		return err
+	}
+	htmlExec := func(text string, data any) error {
+		tmpl, err := htmltemplate.New("main").Parse(text)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(io.Discard, data)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want *TemplateError
+	}{
+		{
+			name: "text parse error without column",
+			err:  textParse("line one\n{{.Foo"),
+			want: &TemplateError{Template: "main", Line: 2, Message: "unclosed action"},
+		},
+		{
+			name: "html parse error",
+			err:  htmlParse("{{if .X}}open"),
+			want: &TemplateError{Template: "main", Line: 1, Message: "unexpected EOF"},
+		},
+		{
+			name: "undefined function",
+			err:  htmlParse("\n\n{{nope}}"),
+			want: &TemplateError{Template: "main", Line: 3, Message: `function "nope" not defined`},
+		},
+		{
+			name: "execution error with column",
+			err:  htmlExec("<p>{{index .Items 5}}</p>", map[string]any{"Items": []int{1}}),
+			want: &TemplateError{Template: "main", Line: 1, Column: 5, Message: `executing "main" at <index .Items 5>: error calling index: index out of range: 5`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("test template did not fail")
+			}
+			var got *TemplateError
+			if !errors.As(templateError(tt.err), &got) {
+				t.Fatalf("templateError(%q) is not a *TemplateError", tt.err)
+			}
+			if *got != *tt.want {
+				t.Errorf("templateError(%q) = %+v, want %+v", tt.err, *got, *tt.want)
+			}
+		})
+	}
+
+	passThrough := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no position", errors.New("something broke"), nil},
+		{"escaping error without line", htmlExec(`<a href="{{.}}`, "x"), nil},
+		{"timeout", &texttemplate.ExecError{Name: "main", Err: ErrTemplateTimeout}, ErrTemplateTimeout},
+		{"canceled", context.Canceled, context.Canceled},
+		{"too large", &limitError{}, ErrTemplateTooLarge},
+	}
+	for _, tt := range passThrough {
+		t.Run(tt.name, func(t *testing.T) {
+			got := templateError(tt.err)
+			want := tt.want
+			if want == nil {
+				want = tt.err
+			}
+			if got != want {
+				t.Errorf("templateError(%v) = %v, want %v", tt.err, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+const urlGuardLookupTimeout = 5 * time.Second
+
+// blockedPrefixes are address ranges a render must never reach: loopback,
+// private, link-local (including cloud metadata endpoints), carrier-grade
+// NAT, documentation, multicast and reserved space.
+var blockedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
+
+// URLGuard keeps renders away from internal services. Only http and https
+// are allowed, and every host is resolved so that names pointing at
+// private, loopback or link-local addresses are refused. AllowHosts and
+// AllowNetworks are operator exceptions.
+type URLGuard struct {
+	allowHosts    []string
+	allowNetworks []netip.Prefix
+	resolver      *net.Resolver
+}
+
+// NewURLGuard builds a guard. allowHosts are host patterns as in
+// NetworkPolicy; allowNetworks are CIDR ranges that may be reached even
+// though they are private.
+func NewURLGuard(allowHosts, allowNetworks []string) (*URLGuard, error) {
+	g := &URLGuard{resolver: net.DefaultResolver}
+	for _, pattern := range allowHosts {
+		if !validHostPattern(pattern) {
+			return nil, fmt.Errorf("invalid allowed host %q", pattern)
+		}
+		g.allowHosts = append(g.allowHosts, pattern)
+	}
+	for _, cidr := range allowNetworks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q: %w", cidr, err)
+		}
+		g.allowNetworks = append(g.allowNetworks, prefix.Masked())
+	}
+	return g, nil
+}
+
+// Check returns an error wrapping ErrURLNotAllowed if rawURL may not be
+// rendered or fetched.
+func (g *URLGuard) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrURLNotAllowed, u.Scheme)
+	}
+	return g.checkHost(ctx, u.Hostname())
+}
+
+func (g *URLGuard) checkHost(ctx context.Context, host string) error {
+	_, err := g.resolve(ctx, host)
+	return err
+}
+
+// resolve returns the addresses host may be reached at, all of them
+// public or allowed. It returns none for an allowed host, which is reached
+// however it resolves.
+func (g *URLGuard) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return nil, fmt.Errorf("%w: missing host", ErrURLNotAllowed)
+	}
+	if matchAnyHost(g.allowHosts, host) {
+		return nil, nil
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, urlGuardLookupTimeout)
+		defer cancel()
+		addrs, err = g.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot resolve %s: %v", ErrURLNotAllowed, host, err)
+		}
+	}
+
+	// Every address has to pass, or a name with one public and one private
+	// record could be steered to the private one.
+	for _, addr := range addrs {
+		if g.addrAllowed(addr.Unmap()) {
+			continue
+		}
+		if addr.String() == host {
+			return nil, fmt.Errorf("%w: %s is not a public address", ErrURLNotAllowed, host)
+		}
+		return nil, fmt.Errorf("%w: %s resolves to non-public address %s", ErrURLNotAllowed, host, addr)
+	}
+	return addrs, nil
+}
+
+// dialContext connects to addr if the guard allows its host, dialing the
+// addresses it checked so the name can't be re-resolved to an internal
+// one in between.
+func (g *URLGuard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	addrs, err := g.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	if addrs == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	var firstErr error
+	for _, a := range addrs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(a.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func (g *URLGuard) addrAllowed(addr netip.Addr) bool {
+	for _, prefix := range g.allowNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// rule checks the requests the render's own tab makes, which covers
+// redirects (each hop pauses again) and subresources, so they are refused
+// early and show up in diagnostics. Results are cached per host for the
+// render. It can't see WebSockets, workers or cross-site frames; the
+// guard's proxy is what enforces the check for those.
+func (g *URLGuard) rule(ctx context.Context, rec *diagnosticsRecorder) requestRule {
+	var (
+		mu    sync.Mutex
+		hosts = make(map[string]error)
+	)
+	return func(ev *fetch.EventRequestPaused) *requestDecision {
+		u, err := url.Parse(ev.Request.URL)
+		if err == nil && u.Scheme != "http" && u.Scheme != "https" {
+			err = fmt.Errorf("%w: scheme %q is not allowed", ErrURLNotAllowed, u.Scheme)
+		}
+		if err == nil {
+			host := strings.ToLower(u.Hostname())
+			mu.Lock()
+			cached, ok := hosts[host]
+			mu.Unlock()
+			if ok {
+				err = cached
+			} else {
+				err = g.checkHost(ctx, host)
+				mu.Lock()
+				hosts[host] = err
+				mu.Unlock()
+			}
+		}
+		if err == nil {
+			return nil
+		}
+
+		rec.blocked(ev.Request.URL, strings.ToLower(string(ev.ResourceType)), err.Error())
+		return &requestDecision{FailReason: network.ErrorReasonAccessDenied}
+	}
+}
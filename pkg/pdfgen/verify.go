@@ -0,0 +1,226 @@
+package pdfgen
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// SignatureStatus is the outcome of checking one signature.
+type SignatureStatus string
+
+const (
+	SignatureValid   SignatureStatus = "valid"
+	SignatureInvalid SignatureStatus = "invalid"
+	SignatureUnknown SignatureStatus = "unknown"
+)
+
+// SignatureInfo describes a signature found by VerifySignatures.
+type SignatureInfo struct {
+	Field       string
+	SubFilter   string
+	SignerName  string
+	Reason      string
+	Location    string
+	ContactInfo string
+	SigningTime time.Time
+	Visible     bool
+	Page        int
+
+	// Status is valid only if the document is unchanged since signing and
+	// the certificate chains up to a trusted root. Detail says why not.
+	Status            SignatureStatus
+	Detail            string
+	Modified          bool
+	Trusted           bool
+	RevocationChecked bool
+	PAdES             string
+	Signer            *CertificateInfo
+	Problems          []string
+	Certified         bool
+}
+
+type CertificateInfo struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	SelfSigned   bool
+	Expired      bool
+}
+
+// pdfcpu validates against a package-level root pool, so verifications
+// take turns setting it.
+var verifyMu sync.Mutex
+
+type VerifyOptions struct {
+	// Roots are the trusted certificate authorities; nil means the
+	// system's.
+	Roots *x509.CertPool
+
+	// CheckRevocation fetches the CRLs and OCSP responses named in the
+	// signers' certificates. Without it signatures are checked offline.
+	// The addresses come from the document, so they are only fetched once
+	// every signature's chain has verified against Roots.
+	CheckRevocation bool
+}
+
+// LoadCertPool returns the system's root CAs plus the PEM certificates in
+// each of paths.
+func LoadCertPool(paths []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// VerifySignatures checks every signature in the PDF read from r. A PDF
+// without signatures returns none and no error.
+func VerifySignatures(r io.ReadSeeker, opts VerifyOptions) ([]SignatureInfo, error) {
+	roots := opts.Roots
+	if roots == nil {
+		var err error
+		if roots, err = x509.SystemCertPool(); err != nil {
+			roots = x509.NewCertPool()
+		}
+	}
+
+	conf := pdfConfig()
+	conf.Cmd = model.VALIDATESIGNATURE
+	conf.Offline = true
+
+	ctx, err := api.ReadValidateAndOptimize(r, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if len(ctx.Signatures) == 0 && ctx.URSignature == nil {
+		return nil, nil
+	}
+
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("%w: reader does not support random access", ErrInvalidPDF)
+	}
+
+	infos, err := validateSignatures(ra, ctx, roots, false)
+	if err != nil || !opts.CheckRevocation {
+		return infos, err
+	}
+
+	// An untrusted certificate can name any address, internal ones
+	// included, so nothing is fetched for a document that has one.
+	for _, info := range infos {
+		if !info.Trusted {
+			for i := range infos {
+				infos[i].Problems = append(infos[i].Problems, "revocation not checked: not every signature chains to a trusted root")
+			}
+			return infos, nil
+		}
+	}
+	ctx.Configuration.Offline = false
+	return validateSignatures(ra, ctx, roots, true)
+}
+
+func validateSignatures(ra io.ReaderAt, ctx *model.Context, roots *x509.CertPool, checkRevocation bool) ([]SignatureInfo, error) {
+	verifyMu.Lock()
+	model.UserCertPool = roots
+	results, err := pdfcpu.ValidateSignatures(ra, ctx, true)
+	verifyMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify signatures: %w", err)
+	}
+
+	infos := make([]SignatureInfo, 0, len(results))
+	for _, res := range results {
+		infos = append(infos, signatureInfo(res, checkRevocation))
+	}
+	return infos, nil
+}
+
+func signatureInfo(res *model.SignatureValidationResult, checkRevocation bool) SignatureInfo {
+	d := res.Details
+	info := SignatureInfo{
+		Field:       d.FieldName,
+		SubFilter:   d.SubFilter,
+		SignerName:  d.SignerName,
+		Reason:      d.Reason,
+		Location:    d.Location,
+		ContactInfo: d.ContactInfo,
+		SigningTime: d.SigningTime,
+		Visible:     res.Signature.Visible,
+		Page:        res.Signature.PageNr,
+		Status:      SignatureUnknown,
+		Detail:      res.Reason.String(),
+		Modified:    res.DocModified == model.True,
+		Certified:   res.Signature.Certified,
+		Problems:    res.Problems,
+
+		RevocationChecked: checkRevocation,
+	}
+	switch res.Status {
+	case model.SignatureStatusValid:
+		info.Status = SignatureValid
+	case model.SignatureStatusInvalid:
+		info.Status = SignatureInvalid
+	}
+	if info.SignerName == "" {
+		info.SignerName = d.SignerIdentity
+	}
+
+	if len(d.Signers) > 0 {
+		signer := d.Signers[0]
+		info.PAdES = signer.PAdES
+		info.Problems = append(info.Problems, signer.Problems...)
+		if c := signer.Certificate; c != nil {
+			// pdfcpu trusts a self-signed certificate even when it isn't
+			// among the roots, so the chain must also have verified.
+			info.Trusted = c.Trust.Status == model.True && !slices.ContainsFunc(signer.Problems, func(p string) bool {
+				return strings.HasPrefix(p, "certificate verification failed")
+			})
+			info.Signer = &CertificateInfo{
+				Subject:      c.Subject,
+				Issuer:       c.Issuer,
+				SerialNumber: c.SerialNumber,
+				NotBefore:    c.ValidFrom,
+				NotAfter:     c.ValidThru,
+				SelfSigned:   c.SelfSigned,
+				Expired:      c.Expired,
+			}
+		}
+
+		// Offline, pdfcpu can't rule out revocation and never reports a
+		// valid signature. An intact signature from a trusted chain is
+		// valid here, with RevocationChecked saying what wasn't looked at.
+		if !checkRevocation && info.Status == SignatureUnknown && res.Reason == model.SignatureReasonCertNotTrusted && info.Trusted && res.DocModified == model.False {
+			info.Status = SignatureValid
+			info.Detail = model.SignatureReasonDocNotModified.String()
+			if info.SubFilter == "ETSI.CAdES.detached" {
+				info.PAdES = "B-B"
+				if signer.HasTimestamp {
+					info.PAdES = "B-T"
+				}
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,212 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	ErrInvalidWaitStrategy = errors.New("invalid wait strategy")
+	ErrWaitTimeout         = errors.New("timed out waiting for page to become ready")
+)
+
+const (
+	DefaultWaitTimeout     = 10 * time.Second
+	DefaultNetworkIdleTime = 500 * time.Millisecond
+
+	waitPollInterval = 100 * time.Millisecond
+)
+
+type WaitCondition string
+
+const (
+	WaitNetworkIdle WaitCondition = "network_idle" // no requests in flight for IdleTime
+	WaitFontsReady  WaitCondition = "fonts_ready"  // document.fonts.ready resolved
+	WaitSelector    WaitCondition = "selector"     // Selector matches a visible element
+	WaitExpression  WaitCondition = "expression"   // Expression evaluates truthy
+	WaitPDFReady    WaitCondition = "pdf_ready"    // the page set window.pdfReady = true
+)
+
+// WaitStrategy is one readiness condition checked after the document loads
+// and before printing. Strategies in PrintOptions.WaitFor run in order.
+type WaitStrategy struct {
+	Condition  WaitCondition
+	IdleTime   time.Duration
+	Selector   string
+	Expression string
+	Timeout    time.Duration // ceiling for this condition, DefaultWaitTimeout if zero
+	Optional   bool          // print anyway when Timeout is reached
+}
+
+func (w WaitStrategy) Validate() error {
+	switch w.Condition {
+	case WaitNetworkIdle, WaitFontsReady, WaitPDFReady:
+	case WaitSelector:
+		if strings.TrimSpace(w.Selector) == "" {
+			return fmt.Errorf("%w: %s requires a selector", ErrInvalidWaitStrategy, w.Condition)
+		}
+	case WaitExpression:
+		if strings.TrimSpace(w.Expression) == "" {
+			return fmt.Errorf("%w: %s requires an expression", ErrInvalidWaitStrategy, w.Condition)
+		}
+	default:
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidWaitStrategy, w.Condition)
+	}
+
+	if w.Timeout < 0 || w.IdleTime < 0 {
+		return fmt.Errorf("%w: durations must not be negative", ErrInvalidWaitStrategy)
+	}
+	return nil
+}
+
+func (w WaitStrategy) timeout() time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	return DefaultWaitTimeout
+}
+
+// pageWaiter runs a list of wait strategies. It must be created before the
+// page starts loading so the network tracker sees every request.
+type pageWaiter struct {
+	strategies []WaitStrategy
+	network    *networkTracker
+}
+
+func newPageWaiter(ctx context.Context, strategies []WaitStrategy) *pageWaiter {
+	w := &pageWaiter{strategies: strategies}
+	for _, s := range strategies {
+		if s.Condition == WaitNetworkIdle {
+			w.network = newNetworkTracker(ctx)
+			break
+		}
+	}
+	return w
+}
+
+func (w *pageWaiter) Do(ctx context.Context) error {
+	for _, s := range w.strategies {
+		if err := w.wait(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *pageWaiter) wait(ctx context.Context, s WaitStrategy) error {
+	waitCtx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	var action chromedp.Action
+	switch s.Condition {
+	case WaitNetworkIdle:
+		idle := s.IdleTime
+		if idle == 0 {
+			idle = DefaultNetworkIdleTime
+		}
+		action = w.network.waitIdle(idle)
+	case WaitFontsReady:
+		action = chromedp.Evaluate(`document.fonts.ready.then(() => true)`, nil,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+				return p.WithAwaitPromise(true)
+			})
+	case WaitSelector:
+		action = chromedp.WaitVisible(s.Selector, chromedp.ByQuery)
+	case WaitExpression:
+		action = pollTruthy(s.Expression)
+	case WaitPDFReady:
+		action = pollTruthy(`window.pdfReady === true`)
+	}
+
+	err := action.Do(waitCtx)
+	if err == nil {
+		return nil
+	}
+
+	// Only our own ceiling counts as a wait timeout; a cancelled or expired
+	// render context is reported as is.
+	timedOut := errors.Is(err, chromedp.ErrPollingTimeout) ||
+		(waitCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil)
+	if !timedOut {
+		return fmt.Errorf("wait for %s: %w", s.Condition, err)
+	}
+	if s.Optional {
+		return nil
+	}
+	return fmt.Errorf("%w: %s not met within %s", ErrWaitTimeout, s.Condition, s.timeout())
+}
+
+func pollTruthy(expression string) chromedp.Action {
+	return chromedp.Poll(expression, nil,
+		chromedp.WithPollingInterval(waitPollInterval),
+		chromedp.WithPollingTimeout(0),
+	)
+}
+
+// networkTracker counts in-flight requests on a tab so we can wait for the
+// network to go quiet.
+type networkTracker struct {
+	mu           sync.Mutex
+	inflight     map[network.RequestID]struct{}
+	lastActivity time.Time
+}
+
+func newNetworkTracker(ctx context.Context) *networkTracker {
+	t := &networkTracker{
+		inflight:     make(map[network.RequestID]struct{}),
+		lastActivity: time.Now(),
+	}
+	chromedp.ListenTarget(ctx, t.handle)
+	return t
+}
+
+func (t *networkTracker) handle(ev any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		t.inflight[ev.RequestID] = struct{}{}
+	case *network.EventLoadingFinished:
+		delete(t.inflight, ev.RequestID)
+	case *network.EventLoadingFailed:
+		delete(t.inflight, ev.RequestID)
+	default:
+		return
+	}
+	t.lastActivity = time.Now()
+}
+
+func (t *networkTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.inflight) > 0 {
+		return 0
+	}
+	return time.Since(t.lastActivity)
+}
+
+func (t *networkTracker) waitIdle(idle time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(waitPollInterval / 2)
+		defer ticker.Stop()
+
+		for t.idleFor() < idle {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,200 @@
+package pdfgen
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var ErrInvalidWatermark = errors.New("invalid watermark")
+
+// WatermarkFonts are the fonts text watermarks can use: the standard PDF
+// fonts every reader has, which cover Latin-1 text only.
+var WatermarkFonts = []string{
+	"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique",
+	"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic",
+	"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique",
+}
+
+var watermarkPositions = []string{"tl", "tc", "tr", "l", "c", "r", "bl", "bc", "br"}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Watermark is a text or image stamped on every selected page. Zero values
+// pick the defaults noted on each field.
+type Watermark struct {
+	// Exactly one of Text and Image is set. Image is a PNG or JPEG.
+	Text  string
+	Image []byte
+
+	Font     string  // one of WatermarkFonts (Helvetica)
+	FontSize int     // points (48)
+	Color    string  // #RRGGBB (#808080)
+	Opacity  float64 // 0 to 1 (0.3)
+	Rotation float64 // degrees counter-clockwise, -180 to 180
+
+	// Position anchors the watermark: tl, tc, tr, l, c, r, bl, bc or br
+	// (c). OffsetX and OffsetY move it from there, in points.
+	Position string
+	OffsetX  float64
+	OffsetY  float64
+
+	// Scale is the watermark's width relative to the page, 0 to 1 (0.5).
+	Scale float64
+
+	// Under puts the watermark behind the page content instead of over it.
+	// Pages with an opaque background hide it.
+	Under bool
+
+	// Pages limits the watermark to some pages, in PageRanges syntax.
+	Pages string
+}
+
+func (w *Watermark) Validate() error {
+	if (w.Text == "") == (len(w.Image) == 0) {
+		return fmt.Errorf("%w: exactly one of text or image is required", ErrInvalidWatermark)
+	}
+	if len(w.Image) > 0 {
+		switch http.DetectContentType(w.Image) {
+		case "image/png", "image/jpeg":
+		default:
+			return fmt.Errorf("%w: image must be PNG or JPEG", ErrInvalidWatermark)
+		}
+	}
+	if w.Font != "" && !slices.Contains(WatermarkFonts, w.Font) {
+		return fmt.Errorf("%w: font must be one of %s", ErrInvalidWatermark, strings.Join(WatermarkFonts, ", "))
+	}
+	if w.FontSize < 0 || w.FontSize > 1000 {
+		return fmt.Errorf("%w: font size must be between 1 and 1000 points", ErrInvalidWatermark)
+	}
+	if w.Color != "" && !hexColorPattern.MatchString(w.Color) {
+		return fmt.Errorf("%w: color must be #RRGGBB", ErrInvalidWatermark)
+	}
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("%w: opacity must be between 0 and 1", ErrInvalidWatermark)
+	}
+	if w.Rotation < -180 || w.Rotation > 180 {
+		return fmt.Errorf("%w: rotation must be between -180 and 180 degrees", ErrInvalidWatermark)
+	}
+	if w.Position != "" && !slices.Contains(watermarkPositions, w.Position) {
+		return fmt.Errorf("%w: position must be one of %s", ErrInvalidWatermark, strings.Join(watermarkPositions, ", "))
+	}
+	if w.Scale < 0 || w.Scale > 1 {
+		return fmt.Errorf("%w: scale must be between 0 and 1", ErrInvalidWatermark)
+	}
+	if w.Pages != "" {
+		if _, err := ParsePageRanges(w.Pages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// description renders w in pdfcpu's watermark description syntax.
+func (w *Watermark) description() string {
+	params := []string{
+		"rotation:" + strconv.FormatFloat(w.Rotation, 'f', -1, 64),
+		"opacity:" + strconv.FormatFloat(cmp.Or(w.Opacity, 0.3), 'f', -1, 64),
+		"scalefactor:" + strconv.FormatFloat(cmp.Or(w.Scale, 0.5), 'f', -1, 64) + " rel",
+		"position:" + cmp.Or(w.Position, "c"),
+		"offset:" + strconv.FormatFloat(w.OffsetX, 'f', -1, 64) + " " + strconv.FormatFloat(w.OffsetY, 'f', -1, 64),
+	}
+	if w.Text != "" {
+		params = append(params,
+			"fontname:"+cmp.Or(w.Font, "Helvetica"),
+			"points:"+strconv.Itoa(cmp.Or(w.FontSize, 48)),
+			"color:"+cmp.Or(w.Color, "#808080"),
+		)
+	}
+	return strings.Join(params, ", ")
+}
+
+func (w *Watermark) apply(pdf *model.Context) error {
+	var (
+		wm  *model.Watermark
+		err error
+	)
+	if w.Text != "" {
+		wm, err = api.TextWatermark(w.Text, w.description(), !w.Under, false, types.POINTS)
+	} else {
+		wm, err = api.ImageWatermarkForReader(bytes.NewReader(w.Image), w.description(), !w.Under, false, types.POINTS)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWatermark, err)
+	}
+
+	selected := types.IntSet{}
+	if w.Pages == "" {
+		for p := 1; p <= pdf.PageCount; p++ {
+			selected[p] = true
+		}
+	} else {
+		ranges, err := ParsePageRanges(w.Pages)
+		if err != nil {
+			return err
+		}
+		pages, err := ExpandPageRanges(ranges, pdf.PageCount)
+		if err != nil {
+			return err
+		}
+		for _, p := range pages {
+			selected[p] = true
+		}
+	}
+
+	if err := pdfcpu.AddWatermarks(pdf, selected, wm); err != nil {
+		return fmt.Errorf("failed to add watermark: %w", err)
+	}
+	return nil
+}
+
+func addWatermarks(pdf *model.Context, watermarks []Watermark) error {
+	for i := range watermarks {
+		if err := watermarks[i].apply(pdf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyWatermarks stamps watermarks, in order, onto the PDF at inputPath
+// and writes the result to outputPath.
+func ApplyWatermarks(ctx context.Context, inputPath string, watermarks []Watermark, outputPath string) error {
+	for i := range watermarks {
+		if err := watermarks[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return transformPDF(ctx, inputPath, outputPath, func(pdf *model.Context) (*model.Context, error) {
+		return pdf, addWatermarks(pdf, watermarks)
+	})
+}
+
+// watermarkPDF is ApplyWatermarks for a PDF held in memory.
+func watermarkPDF(pdf []byte, watermarks []Watermark) ([]byte, error) {
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdf), pdfConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if err := addWatermarks(ctx, watermarks); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}